@@ -0,0 +1,33 @@
+package fsm
+
+import (
+	"fmt"
+	"time"
+)
+
+// runAction calls the action, if ActionTimeout is set, the action runs
+// with a watchdog and the machine stops waiting for it once it times out,
+// the timeout is kept in actionErr to be reported once the transition is done
+func (m *Machine) runAction(action func()) {
+	if m.actionTimeout <= 0 {
+		action()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		action()
+	}()
+
+	timer := time.NewTimer(m.actionTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		if m.actionErr == nil {
+			m.actionErr = fmt.Errorf("action in state %s exceeded %s: %w", m.stateName(m.currentState), m.actionTimeout, ErrActionTimeout)
+		}
+	}
+}
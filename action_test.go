@@ -0,0 +1,40 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestActionTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	conf := doorConfig()
+	conf.ActionTimeout = 10 * time.Millisecond
+	conf.States[3].Entry = func() {
+		<-release
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	err = door.Send(evtOpen)
+	if !errors.Is(err, fsm.ErrActionTimeout) {
+		t.Errorf("expected ErrActionTimeout but got %v", err)
+	}
+
+	if door.State() != opened {
+		t.Errorf("expected the transition to be kept in %d state but got %d", opened, door.State())
+	}
+
+	err = door.Send(evtClose)
+	if err != nil {
+		t.Errorf("expected the machine to keep working but got %s", err)
+	}
+}
@@ -0,0 +1,57 @@
+package fsm
+
+import "sync"
+
+// enter processes the target state and starts its Async action, if any
+func (m *Machine) enter(state State, async func(done func(State))) error {
+	err := m.process(state)
+	if err != nil || async == nil {
+		return err
+	}
+
+	// done only completes the entry it was started for,
+	// the machine might have left the interim state already
+	entry := m.entries
+	m.asyncEntry = entry
+	var once sync.Once
+	go async(func(next State) {
+		once.Do(func() {
+			m.completeAsync(entry, next)
+		})
+	})
+
+	return nil
+}
+
+// leaveEntry cancels the context of the EntryCtx of the state being left
+func (m *Machine) leaveEntry() {
+	if m.cancelEntry != nil {
+		m.cancelEntry()
+		m.cancelEntry = nil
+	}
+}
+
+func (m *Machine) completeAsync(entry uint64, next State) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.stopped || m.entries != entry {
+		return
+	}
+
+	m.guardErr = nil
+	m.actionErr = nil
+
+	if err := m.process(next); err != nil {
+		m.reportError(err)
+	}
+
+	m.reportGuardErr()
+
+	if m.actionErr != nil {
+		m.reportError(m.actionErr)
+		m.actionErr = nil
+	}
+
+	m.drainQueued()
+}
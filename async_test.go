@@ -0,0 +1,267 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestAsyncTarget(t *testing.T) {
+	const (
+		evtUpload = fsm.Event("upload")
+	)
+
+	const (
+		_ fsm.State = iota
+		idle
+		uploading
+		success
+	)
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial: idle,
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event: evtUpload,
+						Targets: fsm.Targets{
+							{
+								Target: uploading,
+								Async: func(done func(fsm.State)) {
+									time.Sleep(50 * time.Millisecond)
+									done(success)
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: uploading,
+			},
+			{
+				Ref: success,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	err = m.Send(evtUpload)
+	if err != nil {
+		t.Errorf("failed to start uploading: %s", err)
+		return
+	}
+
+	if m.State() != uploading {
+		t.Errorf("expected interim %d state but got %d", uploading, m.State())
+	}
+
+	ok := waitFor(time.Second, func() bool {
+		return m.State() == success
+	})
+	if !ok {
+		t.Errorf("expected %d state once done, but got %d", success, m.State())
+	}
+}
+
+func TestEntryCtx(t *testing.T) {
+	canceled := make(chan error, 1)
+
+	conf := doorConfig()
+	conf.States[3].EntryCtx = func(ctx context.Context) {
+		select {
+		case <-ctx.Done():
+			canceled <- ctx.Err()
+		case <-time.After(time.Second):
+			canceled <- nil
+		}
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	for _, evt := range []fsm.Event{evtOpen, evtClose} {
+		err = door.Send(evt)
+		if err != nil {
+			t.Errorf("failed to send %s: %s", evt, err)
+		}
+	}
+
+	err = <-canceled
+	if err != context.Canceled {
+		t.Errorf("expected the entry of the opened door to be canceled once it is closed, but got %v", err)
+	}
+}
+
+func TestQueueDuringPending(t *testing.T) {
+	const (
+		evtUpload = fsm.Event("upload")
+		evtReset  = fsm.Event("reset")
+	)
+
+	const (
+		_ fsm.State = iota
+		idle
+		uploading
+		success
+	)
+
+	var states []fsm.State
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial:            idle,
+		QueueDuringPending: true,
+		StateChanged: func(prev, next fsm.State) {
+			states = append(states, next)
+		},
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event: evtUpload,
+						Targets: fsm.Targets{
+							{
+								Target: uploading,
+								Async: func(done func(fsm.State)) {
+									time.Sleep(50 * time.Millisecond)
+									done(success)
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: uploading,
+			},
+			{
+				Ref: success,
+				On: fsm.On{
+					{
+						Event: evtReset,
+						Targets: fsm.Targets{
+							{
+								Target: idle,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+	defer m.Stop()
+
+	for _, evt := range []fsm.Event{evtUpload, evtReset} {
+		err = m.Send(evt)
+		if err != nil {
+			t.Errorf("failed to send %s: %s", evt, err)
+		}
+	}
+
+	if m.State() != uploading {
+		t.Errorf("expected the reset to wait for the upload in %d state, but got %d", uploading, m.State())
+	}
+
+	ok := waitFor(time.Second, func() bool {
+		return m.TransitionCount() == 3
+	})
+	if !ok || m.State() != idle {
+		t.Errorf("expected the queued reset to move the machine to %d state once uploaded, but got %d", idle, m.State())
+	}
+
+	if len(states) != 3 || states[1] != success {
+		t.Errorf("expected the reset to be applied after the upload settled, but got %v", states)
+	}
+}
+
+func TestQueueDuringPendingTimeout(t *testing.T) {
+	const (
+		evtUpload = fsm.Event("upload")
+		evtRetry  = fsm.Event("retry")
+	)
+
+	const (
+		_ fsm.State = iota
+		idle
+		uploading
+		failed
+		success
+	)
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial:            idle,
+		QueueDuringPending: true,
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event: evtUpload,
+						Targets: fsm.Targets{
+							{
+								Target: uploading,
+								Async: func(done func(fsm.State)) {
+									time.Sleep(200 * time.Millisecond)
+									done(success)
+								},
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: uploading,
+				Timeout: &fsm.Timeout{
+					Duration: 30 * time.Millisecond,
+					Targets:  fsm.Targets{{Target: failed}},
+				},
+			},
+			{
+				Ref: failed,
+				On: fsm.On{
+					{
+						Event:   evtRetry,
+						Targets: fsm.Targets{{Target: idle}},
+					},
+				},
+			},
+			{Ref: success},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+	defer m.Stop()
+
+	for _, evt := range []fsm.Event{evtUpload, evtRetry} {
+		err = m.Send(evt)
+		if err != nil {
+			t.Errorf("failed to send %s: %s", evt, err)
+		}
+	}
+
+	ok := waitFor(time.Second, func() bool {
+		return m.State() == idle
+	})
+	if !ok {
+		t.Errorf("expected the queued retry to be applied once the upload timed out, but got %d state", m.State())
+	}
+}
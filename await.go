@@ -0,0 +1,49 @@
+package fsm
+
+import (
+	"context"
+	"time"
+)
+
+// AwaitStable blocks until the machine reaches a state which is not going to
+// move on its own immediately, which means no zero duration timeout is armed,
+// and returns that state. If ctx is done first, ctx's error is returned. Note
+// that a zero duration timeout which keeps being re-armed because none of its
+// targets passes never settles
+func (m *Machine) AwaitStable(ctx context.Context) (State, error) {
+	return m.awaitTimeouts(ctx, 0)
+}
+
+// SendAndSettle sends an event to machine and waits for all the timeouts with a
+// duration less than or equal to Config.SettleThreshold to fire, so the returned
+// state is the one the machine settles in. Since it blocks for as long as the
+// armed timeouts, a large threshold or a timeout which keeps being re-armed can
+// block it until ctx is done. It must not be called from StateChanged or any other
+// callback which runs during a transition, as it would never be able to settle
+func (m *Machine) SendAndSettle(ctx context.Context, evt Event) (State, error) {
+	err := m.Send(evt)
+	if err != nil {
+		return m.State(), err
+	}
+
+	return m.awaitTimeouts(ctx, m.settle)
+}
+
+func (m *Machine) awaitTimeouts(ctx context.Context, threshold time.Duration) (State, error) {
+	for {
+		m.mu.Lock()
+		if !m.hasTimeoutWithin(threshold) {
+			state := m.currentState
+			m.unlock()
+			return state, nil
+		}
+		changed := m.wait()
+		m.unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
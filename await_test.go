@@ -0,0 +1,176 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestAwaitStable(t *testing.T) {
+	const (
+		EvtSubmit = fsm.Event("submit")
+	)
+
+	const (
+		_ fsm.State = iota
+		idle
+		routing
+		accepted
+		rejected
+	)
+
+	valid := true
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial: idle,
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event: EvtSubmit,
+						Targets: fsm.Targets{
+							{
+								Target: routing,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: routing,
+				Timeout: &fsm.Timeout{
+					Immediate: true,
+					Targets: fsm.Targets{
+						{
+							Cond: func() bool {
+								return valid
+							},
+							Target: accepted,
+						},
+						{
+							Target: rejected,
+						},
+					},
+				},
+			},
+			{
+				Ref: accepted,
+			},
+			{
+				Ref: rejected,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	err = m.Send(EvtSubmit)
+	if err != nil {
+		t.Errorf("failed to send submit: %s", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	state, err := m.AwaitStable(ctx)
+	if err != nil {
+		t.Errorf("failed to await stable state: %s", err)
+		return
+	}
+
+	if state != accepted {
+		t.Errorf("expected %d settled state but got %d", accepted, state)
+	}
+}
+
+func TestSendAndSettle(t *testing.T) {
+	const (
+		EvtStart = fsm.Event("start")
+	)
+
+	const (
+		_ fsm.State = iota
+		idle
+		warmingUp
+		heating
+		running
+	)
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial:         idle,
+		SettleThreshold: 50 * time.Millisecond,
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event: EvtStart,
+						Targets: fsm.Targets{
+							{
+								Target: warmingUp,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: warmingUp,
+				Timeout: &fsm.Timeout{
+					Duration: 10 * time.Millisecond,
+					Targets: fsm.Targets{
+						{
+							Target: heating,
+						},
+					},
+				},
+			},
+			{
+				Ref: heating,
+				Timeout: &fsm.Timeout{
+					Duration: 20 * time.Millisecond,
+					Targets: fsm.Targets{
+						{
+							Target: running,
+						},
+					},
+				},
+			},
+			{
+				Ref: running,
+				Timeout: &fsm.Timeout{
+					Duration: time.Minute,
+					Targets: fsm.Targets{
+						{
+							Target: idle,
+						},
+					},
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	state, err := m.SendAndSettle(ctx, EvtStart)
+	if err != nil {
+		t.Errorf("failed to send and settle: %s", err)
+		return
+	}
+
+	if state != running {
+		t.Errorf("expected %d settled state but got %d", running, state)
+	}
+}
@@ -0,0 +1,21 @@
+package fsm
+
+// BindBus subscribes the machine to a pub/sub system. sub is called once with
+// a handler which translates every received topic to an event using mapping
+// and sends it to the machine. Topics which are not in mapping are ignored and
+// so are the errors returned by Send, as there is no caller to report them to
+func (m *Machine) BindBus(sub func(handler func(topic string)), mapping map[string]Event) {
+	events := make(map[string]Event, len(mapping))
+	for topic, evt := range mapping {
+		events[topic] = evt
+	}
+
+	sub(func(topic string) {
+		evt, ok := events[topic]
+		if !ok {
+			return
+		}
+
+		m.Send(evt)
+	})
+}
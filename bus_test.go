@@ -0,0 +1,114 @@
+package fsm_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+type fakeBus struct {
+	mu       sync.Mutex
+	handlers []func(topic string)
+}
+
+func (b *fakeBus) Subscribe(handler func(topic string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *fakeBus) Publish(topic string) {
+	b.mu.Lock()
+	handlers := b.handlers
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(topic)
+	}
+}
+
+func TestBindBus(t *testing.T) {
+	const (
+		EvtToggle = fsm.Event("toggle")
+	)
+
+	const (
+		_ fsm.State = iota
+		on
+		off
+	)
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial: off,
+		States: fsm.States{
+			{
+				Ref: on,
+				On: fsm.On{
+					{
+						Event: EvtToggle,
+						Targets: fsm.Targets{
+							{
+								Target: off,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: off,
+				On: fsm.On{
+					{
+						Event: EvtToggle,
+						Targets: fsm.Targets{
+							{
+								Target: on,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	bus := &fakeBus{}
+	m.BindBus(bus.Subscribe, map[string]fsm.Event{
+		"button.pressed": EvtToggle,
+	})
+
+	testCases := []struct {
+		description   string
+		topic         string
+		expectedState fsm.State
+	}{
+		{
+			description:   "pressing the button turns it on",
+			topic:         "button.pressed",
+			expectedState: on,
+		},
+		{
+			description:   "unknown topic is ignored",
+			topic:         "button.released",
+			expectedState: on,
+		},
+		{
+			description:   "pressing the button again turns it off",
+			topic:         "button.pressed",
+			expectedState: off,
+		},
+	}
+
+	for _, testCase := range testCases {
+		bus.Publish(testCase.topic)
+
+		if m.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, m.State())
+		}
+	}
+}
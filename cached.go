@@ -0,0 +1,62 @@
+package fsm
+
+import "time"
+
+// CachedCond is a guard whose result is cached on the machine under Key, so an
+// expensive guard runs once for every transition which refers to the same Key
+// until the result is older than TTL or it is invalidated with InvalidateGuard.
+// A TTL of zero keeps the result until it is invalidated
+type CachedCond struct {
+	Key string
+	Fn  func() bool
+	TTL time.Duration
+}
+
+type cachedResult struct {
+	result  bool
+	expires time.Time
+}
+
+// InvalidateGuard drops the cached result of the CachedCond with the given key,
+// so the guard runs again the next time it is checked
+func (m *Machine) InvalidateGuard(key string) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	delete(m.cachedConds, key)
+}
+
+// checkCached returns the cached result of the guard, or runs it once
+// the result is expired. The result of a panicking guard isn't cached
+func (m *Machine) checkCached(cached *CachedCond) bool {
+	now := m.clock.Now()
+
+	if c, ok := m.cachedConds[cached.Key]; ok && (c.expires.IsZero() || now.Before(c.expires)) {
+		return c.result
+	}
+
+	panicked := true
+	result := m.guard(func() bool {
+		result := cached.Fn()
+		panicked = false
+		return result
+	})
+	if panicked {
+		return result
+	}
+
+	var expires time.Time
+	if cached.TTL > 0 {
+		expires = now.Add(cached.TTL)
+	}
+
+	if m.cachedConds == nil {
+		m.cachedConds = make(map[string]cachedResult)
+	}
+	m.cachedConds[cached.Key] = cachedResult{
+		result:  result,
+		expires: expires,
+	}
+
+	return result
+}
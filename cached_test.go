@@ -0,0 +1,88 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestCachedCond(t *testing.T) {
+	clock := newFakeClock(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	calls := 0
+	permitted := &fsm.CachedCond{
+		Key: "permitted",
+		Fn: func() bool {
+			calls++
+			return true
+		},
+		TTL: time.Minute,
+	}
+
+	conf := doorConfig()
+	conf.Clock = clock
+	// both the closed and the unlocked door check the same cached guard
+	conf.States[0].On[1].Cached = permitted
+	conf.States[2].On[0].Cached = permitted
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	testCases := []struct {
+		description   string
+		invalidate    bool
+		advance       time.Duration
+		events        []fsm.Event
+		expectedCalls int
+	}{
+		{
+			description:   "opening the closed door",
+			events:        []fsm.Event{evtOpen},
+			expectedCalls: 1,
+		},
+		{
+			description:   "opening the unlocked door within the TTL",
+			events:        []fsm.Event{evtClose, evtLock, evtUnlock, evtOpen},
+			expectedCalls: 1,
+		},
+		{
+			description:   "opening again after invalidation",
+			invalidate:    true,
+			events:        []fsm.Event{evtClose, evtOpen},
+			expectedCalls: 2,
+		},
+		{
+			description:   "opening again after the TTL",
+			advance:       2 * time.Minute,
+			events:        []fsm.Event{evtClose, evtOpen},
+			expectedCalls: 3,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.invalidate {
+			door.InvalidateGuard("permitted")
+		}
+		clock.Advance(testCase.advance)
+
+		for _, evt := range testCase.events {
+			err = door.Send(evt)
+			if err != nil {
+				t.Errorf("in %s, failed to send %s: %s", testCase.description, evt, err)
+			}
+		}
+
+		if calls != testCase.expectedCalls {
+			t.Errorf("in %s, expected the guard to be called %d times, but got %d", testCase.description, testCase.expectedCalls, calls)
+		}
+
+		if door.State() != opened {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, opened, door.State())
+		}
+	}
+}
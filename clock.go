@@ -0,0 +1,92 @@
+package fsm
+
+import "time"
+
+// Clock is the source of the current time for the machine,
+// it can be replaced in Config to control time in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// MonotonicClock is a Clock which also reads a monotonic clock, one which only
+// moves forward at a steady pace whatever happens to the wall clock. If the
+// machine's Clock implements it, the timeouts are measured on the monotonic
+// clock, an armed timeout only fires once Monotonic moved by its duration, so
+// setting the wall clock doesn't fire it early or late. The system clock is a
+// MonotonicClock, like the runtime's timers it doesn't move while the system
+// is suspended, so the time spent suspended doesn't count towards the timeouts
+type MonotonicClock interface {
+	Clock
+	// Monotonic returns the time elapsed since an arbitrary fixed origin
+	Monotonic() time.Duration
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+var monotonicOrigin = time.Now()
+
+func (realClock) Monotonic() time.Duration {
+	// the reading of time.Now carries the monotonic clock, which Since uses
+	return time.Since(monotonicOrigin)
+}
+
+// monotonic reads the monotonic clock, it is 0 if the Clock doesn't have one
+func (m *Machine) monotonic() time.Duration {
+	if monotonic, ok := m.clock.(MonotonicClock); ok {
+		return monotonic.Monotonic()
+	}
+
+	return 0
+}
+
+// remaining returns the time left until the armed timeout fires
+func (m *Machine) remaining(armed *armedTimeout) time.Duration {
+	if monotonic, ok := m.clock.(MonotonicClock); ok {
+		return armed.due - monotonic.Monotonic()
+	}
+
+	return armed.deadline.Sub(m.clock.Now())
+}
+
+// setTimeout calls fn once the timeout elapsed, unless the returned function
+// is called first. With a MonotonicClock, the timer is re-armed for the rest
+// of the timeout until the monotonic clock moved by the whole timeout
+func setTimeout(clock Clock, fn func(), timeout time.Duration) func() {
+	cancel := make(chan struct{}, 1)
+
+	monotonic, ok := clock.(MonotonicClock)
+	var start time.Duration
+	if ok {
+		start = monotonic.Monotonic()
+	}
+
+	go func() {
+		wait := timeout
+		for {
+			select {
+			case <-time.After(wait):
+			case <-cancel:
+				return
+			}
+
+			if !ok {
+				break
+			}
+
+			wait = timeout - (monotonic.Monotonic() - start)
+			if wait <= 0 {
+				break
+			}
+		}
+
+		fn()
+	}()
+
+	return func() {
+		close(cancel)
+	}
+}
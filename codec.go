@@ -0,0 +1,74 @@
+package fsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// StateCodec converts states to and from their names, so states are written
+// as readable text in JSON or YAML. States without a name are written as their
+// number and both forms are accepted when reading a state back
+type StateCodec struct {
+	names  map[State]string
+	states map[string]State
+}
+
+// NewStateCodec creates a codec from a registry of names, such as Config.Names
+func NewStateCodec(names map[State]string) *StateCodec {
+	c := &StateCodec{
+		names:  make(map[State]string, len(names)),
+		states: make(map[string]State, len(names)),
+	}
+
+	for state, name := range names {
+		c.names[state] = name
+		c.states[name] = state
+	}
+
+	return c
+}
+
+// MarshalState returns the name of the state, or its number if it has no name
+func (c *StateCodec) MarshalState(state State) ([]byte, error) {
+	if name, ok := c.names[state]; ok {
+		return []byte(name), nil
+	}
+
+	return []byte(strconv.FormatUint(uint64(state), 10)), nil
+}
+
+// UnmarshalState returns the state with the given name or number
+func (c *StateCodec) UnmarshalState(data []byte) (State, error) {
+	if state, ok := c.states[string(data)]; ok {
+		return state, nil
+	}
+
+	n, err := strconv.ParseUint(string(data), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("state %q: %w", data, ErrStateNotFound)
+	}
+
+	return State(n), nil
+}
+
+// StateCodec returns a codec built from the machine's Names
+func (m *Machine) StateCodec() *StateCodec {
+	return NewStateCodec(m.names)
+}
+
+// MarshalJSON writes the current state of the machine using its name
+func (m *Machine) MarshalJSON() ([]byte, error) {
+	state := m.State()
+
+	text, err := m.StateCodec().MarshalState(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		State string `json:"state"`
+	}{
+		State: string(text),
+	})
+}
@@ -0,0 +1,77 @@
+package fsm_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestStateCodec(t *testing.T) {
+	codec := fsm.NewStateCodec(doorConfig().Names)
+
+	testCases := []struct {
+		description string
+		state       fsm.State
+		text        string
+	}{
+		{
+			description: "named state",
+			state:       locked,
+			text:        "Locked",
+		},
+		{
+			description: "unnamed state",
+			state:       locked + 1,
+			text:        "5",
+		},
+	}
+
+	for _, testCase := range testCases {
+		text, err := codec.MarshalState(testCase.state)
+		if err != nil {
+			t.Errorf("in %s, failed to marshal state: %s", testCase.description, err)
+			continue
+		}
+
+		if string(text) != testCase.text {
+			t.Errorf("in %s, expected %q but got %q", testCase.description, testCase.text, text)
+		}
+
+		state, err := codec.UnmarshalState(text)
+		if err != nil {
+			t.Errorf("in %s, failed to unmarshal state: %s", testCase.description, err)
+			continue
+		}
+
+		if state != testCase.state {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.state, state)
+		}
+	}
+
+	_, err := codec.UnmarshalState([]byte("Ajar"))
+	if !errors.Is(err, fsm.ErrStateNotFound) {
+		t.Errorf("expected %s error for an unknown name, but got %v", fsm.ErrStateNotFound, err)
+	}
+}
+
+func TestMachineMarshalJSON(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	data, err := json.Marshal(door)
+	if err != nil {
+		t.Errorf("failed to marshal door: %s", err)
+		return
+	}
+
+	expected := `{"state":"Closed"}`
+	if string(data) != expected {
+		t.Errorf("expected %s but got %s", expected, data)
+	}
+}
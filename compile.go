@@ -0,0 +1,133 @@
+package fsm
+
+import "fmt"
+
+// CompiledConfig is a validated configuration whose lookup tables are built
+// once and shared by every machine created from it with NewFromCompiled
+type CompiledConfig struct {
+	conf       Config
+	states     map[State]*stateInfo
+	nextStates map[key]*stateEventInfo
+	order      []State
+	groups     map[string]map[State]bool
+	aliases    map[Event]Event
+}
+
+// CompileConfig validates the configuration like NewMachine and builds its lookup
+// tables, so many machines can be created from it cheaply. A sub machine is a
+// single running machine, so a configuration with sub machines can't be shared
+// and is rejected with ErrSharedSubMachine
+func CompileConfig(conf Config) (*CompiledConfig, error) {
+	compiled, err := compile(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := compiled.states[conf.Initial]; !ok {
+		return nil, fmt.Errorf("initial state %d: %w", conf.Initial, ErrStateNotFound)
+	}
+
+	for _, ref := range compiled.order {
+		if compiled.states[ref].SubMachine != nil {
+			return nil, fmt.Errorf("state ref %d: %w", ref, ErrSharedSubMachine)
+		}
+	}
+
+	return compiled, nil
+}
+
+// NewFromCompiled creates a new machine from a compiled configuration and enters
+// its initial state like NewMachine does. The configuration is already validated,
+// but entering the initial state can still fail like it does for NewMachine, for
+// example if its Always transitions are ambiguous or its Entry times out
+func NewFromCompiled(compiled *CompiledConfig) (*Machine, error) {
+	m := compiled.machine()
+
+	m.mu.Lock()
+	err := m.process(compiled.conf.Initial)
+	if err == nil {
+		err = m.actionErr
+	}
+	m.actionErr = nil
+	m.armLifetime()
+	m.unlock()
+	if err != nil {
+		m.Stop()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// machine creates a machine which uses the compiled lookup tables
+func (c *CompiledConfig) machine() *Machine {
+	conf := c.conf
+
+	clock := conf.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+
+	random := conf.Rand
+	if random == nil {
+		random = defaultRand
+	}
+
+	tracer := conf.Tracer
+	if tracer == nil {
+		tracer = noopTracer{}
+	}
+
+	var d *dispatcher
+	if conf.AsyncCallbacks {
+		d = newDispatcher()
+	}
+
+	var errHandlers []errorHandler
+	if conf.OnError != nil {
+		errHandlers = append(errHandlers, errorHandler{fn: conf.OnError})
+	}
+
+	return &Machine{
+		dispatcher:     d,
+		errHandlers:    errHandlers,
+		rand:           random,
+		stateChanged:   conf.StateChanged,
+		clock:          clock,
+		names:          conf.Names,
+		settle:         conf.SettleThreshold,
+		cacheGuards:    conf.CacheGuards,
+		actionTimeout:  conf.ActionTimeout,
+		onTimeout:      conf.OnTimeout,
+		strictSend:     conf.StrictSend,
+		onGuardError:   conf.OnGuardError,
+		selection:      conf.TargetSelection,
+		recordTimeline: conf.RecordTimeline,
+		requireConfirm: conf.RequireConfirm,
+		maxLifetime:    conf.MaxLifetime,
+		expireTarget:   conf.ExpireTarget,
+		rejectionLimit: conf.RecordRejections,
+		onArmed:        conf.OnTimeoutArmed,
+		onCanceled:     conf.OnTimeoutCanceled,
+		warnings:       conf.Warnings,
+		contiguous:     conf.ExpectContiguousFrom,
+		strictReach:    conf.StrictReachability,
+		rateInterval:   conf.RateInterval,
+		rateRetention:  conf.RateRetention,
+		queuePending:   conf.QueueDuringPending,
+		guards:         conf.Guards,
+		transitionLog:  conf.TransitionLog,
+		coalesce:       conf.TimeoutCoalesce,
+		tracer:         tracer,
+		coalesceCalls:  conf.CoalesceCallbacks,
+		aliases:        c.aliases,
+		stepMode:       conf.StepMode,
+		currentState:   conf.Initial,
+		initial:        conf.Initial,
+		previous:       conf.Initial,
+		order:          c.order,
+		groups:         c.groups,
+		nextStates:     c.nextStates,
+		states:         c.states,
+	}
+}
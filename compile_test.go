@@ -0,0 +1,100 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestNewFromCompiled(t *testing.T) {
+	compiled, err := fsm.CompileConfig(doorConfig())
+	if err != nil {
+		t.Errorf("failed to compile door config: %s", err)
+		return
+	}
+
+	first, err := fsm.NewFromCompiled(compiled)
+	if err != nil {
+		t.Errorf("failed to create the first door: %s", err)
+		return
+	}
+	defer first.Stop()
+
+	second, err := fsm.NewFromCompiled(compiled)
+	if err != nil {
+		t.Errorf("failed to create the second door: %s", err)
+		return
+	}
+	defer second.Stop()
+
+	err = first.Send(evtOpen)
+	if err != nil {
+		t.Errorf("failed to open the first door: %s", err)
+	}
+
+	if first.State() != opened {
+		t.Errorf("expected the first door in %d state but got %d", opened, first.State())
+	}
+
+	if second.State() != closed {
+		t.Errorf("expected the second door to stay in %d state but got %d", closed, second.State())
+	}
+
+	conf := doorConfig()
+	conf.Initial = locked + 1
+
+	_, err = fsm.CompileConfig(conf)
+	if !errors.Is(err, fsm.ErrStateNotFound) {
+		t.Errorf("expected %s error for an unknown initial state, but got %v", fsm.ErrStateNotFound, err)
+	}
+
+	// both Always transitions of the initial state pass
+	conf = doorConfig()
+	conf.Initial = unlocked
+	conf.TargetSelection = fsm.ExactlyOne
+	pass := func() bool {
+		return true
+	}
+	conf.States[2].Always = fsm.Targets{{Cond: pass, Target: closed}, {Cond: pass, Target: opened}}
+
+	compiled, err = fsm.CompileConfig(conf)
+	if err != nil {
+		t.Errorf("failed to compile door config: %s", err)
+		return
+	}
+
+	_, err = fsm.NewFromCompiled(compiled)
+	if !errors.Is(err, fsm.ErrAmbiguousTarget) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrAmbiguousTarget, err)
+	}
+}
+
+func BenchmarkNewMachine(b *testing.B) {
+	conf := doorConfig()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m, err := fsm.NewMachine(conf)
+		if err != nil {
+			b.Fatalf("failed to create door fsm: %s", err)
+		}
+		m.Stop()
+	}
+}
+
+func BenchmarkNewFromCompiled(b *testing.B) {
+	compiled, err := fsm.CompileConfig(doorConfig())
+	if err != nil {
+		b.Fatalf("failed to compile door config: %s", err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m, err := fsm.NewFromCompiled(compiled)
+		if err != nil {
+			b.Fatalf("failed to create door fsm: %s", err)
+		}
+		m.Stop()
+	}
+}
@@ -0,0 +1,92 @@
+package fsm_test
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestConcurrentDoor(t *testing.T) {
+	const (
+		senders  = 8
+		readers  = 4
+		duration = 200 * time.Millisecond
+	)
+
+	declared := map[fsm.State]bool{
+		unlocked: true,
+		closed:   true,
+		opened:   true,
+		locked:   true,
+	}
+
+	events := []fsm.Event{evtOpen, evtClose, evtLock, evtUnlock}
+
+	conf := doorConfig()
+	// keeps the auto-lock firing while events are being sent
+	conf.States[0].Timeout.Duration = time.Millisecond
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+
+			r := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				door.Send(events[r.Intn(len(events))])
+			}
+		}(int64(i))
+	}
+
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if state := door.State(); !declared[state] {
+					t.Errorf("observed undeclared %d state", state)
+				}
+
+				door.AllowedEvents()
+
+				snapshot := door.Snapshot()
+				if !declared[snapshot.State] {
+					t.Errorf("observed undeclared %d state in snapshot", snapshot.State)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(duration)
+	close(done)
+	wg.Wait()
+
+	if state := door.State(); !declared[state] {
+		t.Errorf("machine ended in undeclared %d state", state)
+	}
+}
@@ -0,0 +1,57 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+type userKey struct{}
+
+func TestSendContext(t *testing.T) {
+	conf := doorConfig()
+	conf.States[0].On[1].CondCtx = func(ctx context.Context) bool {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user == "owner"
+	}
+
+	testCases := []struct {
+		description   string
+		user          string
+		sendError     error
+		expectedState fsm.State
+	}{
+		{
+			description:   "stranger can't open the door",
+			user:          "stranger",
+			sendError:     fsm.ErrCondFailed,
+			expectedState: closed,
+		},
+		{
+			description:   "owner opens the door",
+			user:          "owner",
+			sendError:     nil,
+			expectedState: opened,
+		},
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	for _, testCase := range testCases {
+		ctx := context.WithValue(context.Background(), userKey{}, testCase.user)
+
+		err = door.SendContext(ctx, evtOpen)
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %s, but got %s error", testCase.description, testCase.sendError, err)
+		}
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package fsm
+
+import "sync/atomic"
+
+// TransitionCount returns the number of times the machine has changed its state,
+// including the ones caused by timeouts and Always transitions
+func (m *Machine) TransitionCount() uint64 {
+	return atomic.LoadUint64(&m.transitions)
+}
+
+// EventCount returns the number of times the given event has been sent to machine
+func (m *Machine) EventCount(evt Event) uint64 {
+	m.mu.Lock()
+	defer m.unlock()
+
+	return m.eventCounts[evt]
+}
+
+// NoopCount returns the number of times Send has returned ErrNoop,
+// or ErrUnknownEvent in strict mode
+func (m *Machine) NoopCount() uint64 {
+	return atomic.LoadUint64(&m.noops)
+}
+
+// ResetCounters sets all the counters back to zero
+func (m *Machine) ResetCounters() {
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.resetCounters()
+}
+
+func (m *Machine) resetCounters() {
+	atomic.StoreUint64(&m.transitions, 0)
+	atomic.StoreUint64(&m.noops, 0)
+	m.eventCounts = nil
+}
+
+func (m *Machine) countEvent(evt Event) {
+	if m.eventCounts == nil {
+		m.eventCounts = make(map[Event]uint64)
+	}
+	m.eventCounts[evt]++
+}
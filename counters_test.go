@@ -0,0 +1,63 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestCounters(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	for _, evt := range []fsm.Event{evtOpen, evtOpen, evtClose, evtUnlock, evtLock, evtUnlock, evtOpen} {
+		door.Send(evt)
+	}
+
+	testCases := []struct {
+		description string
+		got         uint64
+		expected    uint64
+	}{
+		{
+			description: "transitions",
+			got:         door.TransitionCount(),
+			expected:    5,
+		},
+		{
+			description: "noops",
+			got:         door.NoopCount(),
+			expected:    2,
+		},
+		{
+			description: "open events",
+			got:         door.EventCount(evtOpen),
+			expected:    3,
+		},
+		{
+			description: "unlock events",
+			got:         door.EventCount(evtUnlock),
+			expected:    2,
+		},
+		{
+			description: "unknown events",
+			got:         door.EventCount("unknown"),
+			expected:    0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.got != testCase.expected {
+			t.Errorf("expected %d %s, but got %d", testCase.expected, testCase.description, testCase.got)
+		}
+	}
+
+	door.ResetCounters()
+
+	if door.TransitionCount() != 0 || door.NoopCount() != 0 || door.EventCount(evtOpen) != 0 {
+		t.Errorf("expected counters to be reset")
+	}
+}
@@ -0,0 +1,64 @@
+package fsm
+
+// CoverageSequences returns sequences of events which, each sent to a fresh
+// machine starting from its initial state, together take every event transition
+// reachable by events at least once. Guards are ignored, so replaying the
+// sequences only covers every transition if the guards pass, and an event with
+// several targets is considered covered once it is sent from its state
+func (m *Machine) CoverageSequences() [][]Event {
+	m.mu.Lock()
+	defer m.unlock()
+
+	covered := make(map[key]bool)
+	var pending []transitionEdge
+	for _, edge := range m.walk() {
+		k := key{edge.From, edge.Event}
+		if edge.Event == "" || covered[k] {
+			continue
+		}
+		covered[k] = true
+
+		if _, ok := m.pathTo(m.initial, edge.From, false); ok {
+			pending = append(pending, edge)
+		}
+	}
+
+	var sequences [][]Event
+	for len(pending) > 0 {
+		sequence := []Event{}
+		current := m.initial
+
+		for {
+			// the nearest uncovered transition is taken next
+			next := -1
+			var path []transitionEdge
+			for i, edge := range pending {
+				p, ok := m.pathTo(current, edge.From, false)
+				if ok && (next < 0 || len(p) < len(path)) {
+					next, path = i, p
+				}
+			}
+
+			if next < 0 {
+				break
+			}
+
+			path = append(path, pending[next])
+			for _, edge := range path {
+				sequence = append(sequence, edge.Event)
+				current = edge.To
+
+				for i, p := range pending {
+					if p.From == edge.From && p.Event == edge.Event {
+						pending = append(pending[:i], pending[i+1:]...)
+						break
+					}
+				}
+			}
+		}
+
+		sequences = append(sequences, sequence)
+	}
+
+	return sequences
+}
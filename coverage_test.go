@@ -0,0 +1,58 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestCoverageSequences(t *testing.T) {
+	type transition struct {
+		from fsm.State
+		evt  fsm.Event
+		to   fsm.State
+	}
+
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	expected := make(map[transition]bool)
+	door.Walk(func(from fsm.State, evt fsm.Event, to fsm.State, isTimeout bool) bool {
+		if evt != "" {
+			expected[transition{from, evt, to}] = true
+		}
+		return true
+	})
+
+	visited := make(map[transition]bool)
+	for _, sequence := range door.CoverageSequences() {
+		replay, err := fsm.NewMachine(doorConfig())
+		if err != nil {
+			t.Errorf("failed to create door fsm: %s", err)
+			return
+		}
+
+		for _, evt := range sequence {
+			from := replay.State()
+
+			err = replay.Send(evt)
+			if err != nil {
+				t.Errorf("failed to replay %s from %d in %v: %s", evt, from, sequence, err)
+				break
+			}
+
+			visited[transition{from, evt, replay.State()}] = true
+		}
+
+		replay.Stop()
+	}
+
+	for transition := range expected {
+		if !visited[transition] {
+			t.Errorf("expected %+v transition to be covered", transition)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package fsm
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+)
+
+// ExportCSV returns the transition table of the machine as CSV with a header row
+// and one row per transition target in the form of from,event,guard,target,timeout_ms.
+// Always and timeout transitions have an empty event and only timeouts have a
+// timeout_ms, states are written using their names
+func (m *Machine) ExportCSV() string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"from", "event", "guard", "target", "timeout_ms"})
+
+	for _, edge := range m.allEdges() {
+		timeout := ""
+		if edge.IsTimeout {
+			timeout = strconv.FormatInt(edge.Duration.Milliseconds(), 10)
+		}
+
+		w.Write([]string{
+			m.stateName(edge.From),
+			string(edge.Event),
+			strconv.FormatBool(edge.Guarded),
+			m.stateName(edge.To),
+			timeout,
+		})
+	}
+
+	w.Flush()
+
+	return buf.String()
+}
@@ -0,0 +1,41 @@
+package fsm_test
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestExportCSV(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	records, err := csv.NewReader(strings.NewReader(door.ExportCSV())).ReadAll()
+	if err != nil {
+		t.Errorf("failed to parse csv: %s", err)
+		return
+	}
+
+	// 6 event transitions, 1 timeout transition and the header
+	if len(records) != 8 {
+		t.Errorf("expected 8 rows, but got %d", len(records))
+		return
+	}
+
+	expected := []string{"Closed", "", "false", "Locked", "10000"}
+	found := false
+	for _, record := range records {
+		if strings.Join(record, ",") == strings.Join(expected, ",") {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("expected timeout row %v, but got %v", expected, records)
+	}
+}
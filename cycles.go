@@ -0,0 +1,63 @@
+package fsm
+
+import "sort"
+
+// Cycles returns the elementary cycles of the transition graph reachable from the
+// initial state, considering event, Always and timeout transitions and ignoring
+// guards. Every cycle starts from its smallest state and is reported once
+func (m *Machine) Cycles() [][]State {
+	m.mu.Lock()
+	edges := m.walk()
+	m.unlock()
+
+	successors := make(map[State][]State)
+	seen := make(map[transitionEdge]bool)
+	var nodes []State
+	for _, edge := range edges {
+		if _, ok := successors[edge.From]; !ok {
+			nodes = append(nodes, edge.From)
+		}
+
+		// multiple edges between the same states form the same cycle
+		pair := transitionEdge{From: edge.From, To: edge.To}
+		if seen[pair] {
+			continue
+		}
+		seen[pair] = true
+		successors[edge.From] = append(successors[edge.From], edge.To)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+	for _, next := range successors {
+		sort.Slice(next, func(i, j int) bool { return next[i] < next[j] })
+	}
+
+	var cycles [][]State
+
+	for _, start := range nodes {
+		path := []State{start}
+		onPath := map[State]bool{start: true}
+
+		var search func(state State)
+		search = func(state State) {
+			for _, next := range successors[state] {
+				switch {
+				case next == start:
+					cycle := make([]State, len(path))
+					copy(cycle, path)
+					cycles = append(cycles, cycle)
+				case next > start && !onPath[next]:
+					path = append(path, next)
+					onPath[next] = true
+					search(next)
+					onPath[next] = false
+					path = path[:len(path)-1]
+				}
+			}
+		}
+
+		search(start)
+	}
+
+	return cycles
+}
@@ -0,0 +1,37 @@
+package fsm_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestCycles(t *testing.T) {
+	light, err := fsm.NewMachine(trafficLightConfig(time.Minute))
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	cycles := light.Cycles()
+
+	expected := [][]fsm.State{{red, green, yellow}}
+	if fmt.Sprint(cycles) != fmt.Sprint(expected) {
+		t.Errorf("expected %v cycles, but got %v", expected, cycles)
+	}
+
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	// Unlocked -> Opened -> Closed -> Locked -> Unlocked
+	// Unlocked -> Locked -> Unlocked
+	// Closed -> Opened -> Closed
+	if len(door.Cycles()) != 3 {
+		t.Errorf("expected 3 cycles for the door, but got %v", door.Cycles())
+	}
+}
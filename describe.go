@@ -0,0 +1,53 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Describe returns a human readable summary of the machine's current situation,
+// the current state, how long the machine has been in it, the armed timeout and
+// the events the current state accepts along with their targets
+func (m *Machine) Describe() string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	var sb strings.Builder
+
+	now := m.clock.Now()
+
+	fmt.Fprintf(&sb, "state: %s\n", m.stateName(m.currentState))
+	fmt.Fprintf(&sb, "in state: %s\n", now.Sub(m.enteredAt))
+
+	for _, armed := range m.timeouts {
+		fmt.Fprintf(
+			&sb,
+			"timeout: %s (remaining %s) -> %s\n",
+			armed.timeout.longest(),
+			m.remaining(armed),
+			m.targetNames(armed.timeout.Targets),
+		)
+	}
+
+	events := m.allowedEvents()
+	if len(events) == 0 {
+		sb.WriteString("events: none\n")
+		return sb.String()
+	}
+
+	sb.WriteString("events:\n")
+	for _, evt := range events {
+		fmt.Fprintf(&sb, "  %s -> %s\n", evt, m.targetNames(m.nextStates[key{m.currentState, evt}].Targets))
+	}
+
+	return sb.String()
+}
+
+func (m *Machine) targetNames(targets Targets) string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, m.stateName(target.Target))
+	}
+
+	return strings.Join(names, " | ")
+}
@@ -0,0 +1,40 @@
+package fsm_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestDescribe(t *testing.T) {
+	clock := newFakeClock(time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC))
+
+	conf := doorConfig()
+	conf.Clock = clock
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	clock.Advance(2 * time.Second)
+
+	description := door.Describe()
+
+	expected := []string{
+		"state: Closed",
+		"in state: 2s",
+		"timeout: 10s (remaining 8s) -> Locked",
+		"lock -> Locked",
+		"open -> Opened",
+	}
+
+	for _, value := range expected {
+		if !strings.Contains(description, value) {
+			t.Errorf("expected description to contain %q, but got:\n%s", value, description)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package fsm
+
+import "sync"
+
+// dispatcher runs callbacks one after another in their own goroutine,
+// so slow callbacks don't hold the machine's lock
+type dispatcher struct {
+	mu     sync.Mutex
+	queue  []func()
+	wake   chan struct{}
+	closed bool
+}
+
+func newDispatcher() *dispatcher {
+	d := &dispatcher{
+		wake: make(chan struct{}, 1),
+	}
+	go d.run()
+
+	return d
+}
+
+// push queues the callback, it never blocks
+func (d *dispatcher) push(fn func()) {
+	d.mu.Lock()
+	d.queue = append(d.queue, fn)
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// close stops the goroutine once the queued callbacks ran
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dispatcher) run() {
+	for range d.wake {
+		for {
+			d.mu.Lock()
+			queue, closed := d.queue, d.closed
+			d.queue = nil
+			d.mu.Unlock()
+
+			if len(queue) == 0 {
+				if closed {
+					return
+				}
+				break
+			}
+
+			for _, fn := range queue {
+				fn()
+			}
+		}
+	}
+}
+
+// callback calls fn once the lock is released, or queues it
+// in the dispatcher if callbacks are asynchronous
+func (m *Machine) callback(fn func()) {
+	if m.dispatcher == nil {
+		m.callLater(fn)
+		return
+	}
+
+	m.dispatcher.push(fn)
+}
@@ -0,0 +1,119 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToDOT returns the transition graph of the machine in Graphviz DOT format,
+// states are written using their names, timeouts are dashed and labeled with
+// their duration, guarded transitions are marked with [cond] and the
+// transitions' Label and Color are honored
+func (m *Machine) ToDOT() string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	return m.toDOT(false, nil)
+}
+
+// ToDOTHighlight returns the same graph as ToDOT with the current state filled
+// and the edges of the armed timeouts drawn bold, so a live machine can be
+// rendered while it is being debugged
+func (m *Machine) ToDOTHighlight() string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	return m.toDOT(true, nil)
+}
+
+// ToDOTSubset returns the same graph as ToDOT limited to the given states and
+// the transitions among them, so a part of a large machine can be focused on.
+// The transitions leading to other states are drawn as dashed stubs to a
+// single marker node
+func (m *Machine) ToDOTSubset(states []State) string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	subset := make(map[State]bool, len(states))
+	for _, state := range states {
+		subset[state] = true
+	}
+
+	return m.toDOT(false, subset)
+}
+
+// toDOT writes the graph, if subset is defined, only its states are written
+func (m *Machine) toDOT(highlight bool, subset map[State]bool) string {
+	included := func(state State) bool {
+		return subset == nil || subset[state]
+	}
+
+	armed := make(map[*Timeout]bool)
+	if highlight {
+		for _, a := range m.timeouts {
+			armed[a.timeout] = true
+		}
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("digraph fsm {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	sb.WriteString("\t__start [shape=point];\n")
+
+	for _, ref := range m.order {
+		if !included(ref) {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "\t%q", m.stateName(ref))
+		if highlight && ref == m.currentState {
+			sb.WriteString(" [fillcolor=lightblue, style=filled]")
+		}
+		sb.WriteString(";\n")
+	}
+
+	if included(m.initial) {
+		fmt.Fprintf(&sb, "\t__start -> %q;\n", m.stateName(m.initial))
+	}
+
+	outside := false
+	for _, edge := range m.allEdges() {
+		if !included(edge.From) {
+			continue
+		}
+
+		var attrs []string
+		if label := edge.label(); label != "" {
+			attrs = append(attrs, fmt.Sprintf("label=%q", label))
+		}
+		if edge.Color != "" {
+			attrs = append(attrs, fmt.Sprintf("color=%q", edge.Color))
+		}
+		if edge.IsTimeout && armed[edge.Timeout] && edge.From == m.currentState {
+			attrs = append(attrs, "style=bold")
+		} else if edge.IsTimeout || !included(edge.To) {
+			attrs = append(attrs, "style=dashed")
+		}
+
+		to := fmt.Sprintf("%q", m.stateName(edge.To))
+		if !included(edge.To) {
+			to = "__outside"
+			outside = true
+		}
+
+		fmt.Fprintf(&sb, "\t%q -> %s", m.stateName(edge.From), to)
+		if len(attrs) > 0 {
+			fmt.Fprintf(&sb, " [%s]", strings.Join(attrs, ", "))
+		}
+		sb.WriteString(";\n")
+	}
+
+	if outside {
+		sb.WriteString("\t__outside [shape=point];\n")
+	}
+
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
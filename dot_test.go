@@ -0,0 +1,134 @@
+package fsm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestToDOT(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	dot := door.ToDOT()
+
+	expected := []string{
+		"digraph fsm {\n",
+		"\t__start -> \"Closed\";\n",
+		"\t\"Closed\" -> \"Locked\" [label=\"lock\"];\n",
+		"\t\"Closed\" -> \"Locked\" [label=\"after 10s\", style=dashed];\n",
+		"\t\"Opened\" -> \"Closed\" [label=\"close\"];\n",
+	}
+
+	for _, value := range expected {
+		if !strings.Contains(dot, value) {
+			t.Errorf("expected DOT to contain %q, but got:\n%s", value, dot)
+		}
+	}
+
+	if strings.Contains(dot, "filled") {
+		t.Errorf("expected no highlight in plain DOT, but got:\n%s", dot)
+	}
+}
+
+func TestToDOTHighlight(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	dot := door.ToDOTHighlight()
+
+	expected := []string{
+		"\t\"Closed\" [fillcolor=lightblue, style=filled];\n",
+		"\t\"Closed\" -> \"Locked\" [label=\"after 10s\", style=bold];\n",
+		"\t\"Opened\";\n",
+	}
+
+	for _, value := range expected {
+		if !strings.Contains(dot, value) {
+			t.Errorf("expected DOT to contain %q, but got:\n%s", value, dot)
+		}
+	}
+
+	door.Send(evtOpen)
+	dot = door.ToDOTHighlight()
+
+	expected = []string{
+		"\t\"Opened\" [fillcolor=lightblue, style=filled];\n",
+		"\t\"Closed\" -> \"Locked\" [label=\"after 10s\", style=dashed];\n",
+	}
+
+	for _, value := range expected {
+		if !strings.Contains(dot, value) {
+			t.Errorf("expected DOT to contain %q after opening, but got:\n%s", value, dot)
+		}
+	}
+}
+
+func TestToDOTAnnotations(t *testing.T) {
+	conf := doorConfig()
+	conf.States[1].On[0].Label = "badge"
+	conf.States[1].On[0].Color = "red"
+	conf.States[0].Timeout.Targets[0].Color = "gray"
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	dot := door.ToDOT()
+
+	expected := []string{
+		"\t\"Locked\" -> \"Unlocked\" [label=\"badge\", color=\"red\"];\n",
+		"\t\"Closed\" -> \"Locked\" [label=\"after 10s\", color=\"gray\", style=dashed];\n",
+	}
+
+	for _, value := range expected {
+		if !strings.Contains(dot, value) {
+			t.Errorf("expected DOT to contain %q, but got:\n%s", value, dot)
+		}
+	}
+
+	uml := door.ToPlantUML()
+	if !strings.Contains(uml, "Locked -[#red]-> Unlocked : badge\n") {
+		t.Errorf("expected PlantUML to contain the colored badge transition, but got:\n%s", uml)
+	}
+}
+
+func TestToDOTSubset(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	dot := door.ToDOTSubset([]fsm.State{locked, unlocked})
+
+	expected := []string{
+		"\t\"Locked\";\n",
+		"\t\"Unlocked\";\n",
+		"\t\"Locked\" -> \"Unlocked\" [label=\"unlock\"];\n",
+		"\t\"Unlocked\" -> __outside [label=\"open\", style=dashed];\n",
+		"\t__outside [shape=point];\n",
+	}
+
+	for _, value := range expected {
+		if !strings.Contains(dot, value) {
+			t.Errorf("expected DOT to contain %q, but got:\n%s", value, dot)
+		}
+	}
+
+	for _, value := range []string{"\"Closed\"", "\"Opened\"", "__start ->"} {
+		if strings.Contains(dot, value) {
+			t.Errorf("expected DOT not to contain %q, but got:\n%s", value, dot)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package fsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// dumpedState is the runtime of a machine as written by DumpState
+type dumpedState struct {
+	State       State             `json:"state"`
+	Previous    State             `json:"previous"`
+	EnteredAt   time.Time         `json:"entered_at"`
+	Paused      bool              `json:"paused,omitempty"`
+	Timeouts    []dumpedTimeout   `json:"timeouts,omitempty"`
+	Transitions uint64            `json:"transitions"`
+	Noops       uint64            `json:"noops"`
+	EventCounts map[Event]uint64  `json:"event_counts,omitempty"`
+	Timeline    []dumpedOccupancy `json:"timeline,omitempty"`
+	Rejections  []dumpedRejection `json:"rejections,omitempty"`
+}
+
+// dumpedTimeout is an armed timeout, Index is its position among the timeouts of the state
+type dumpedTimeout struct {
+	Index     int           `json:"index"`
+	Remaining time.Duration `json:"remaining"`
+}
+
+type dumpedOccupancy struct {
+	State State     `json:"state"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type dumpedRejection struct {
+	State  State     `json:"state"`
+	Event  Event     `json:"event"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// DumpState captures the whole runtime of the machine as JSON, so it can be
+// loaded into another machine with LoadState, e.g. to debug a production issue
+// locally. Besides the current and previous state and when it was entered, the
+// dump holds the remaining time of the armed timeouts, whether the machine is
+// paused, the counters, the recorded timeline and the recorded rejections.
+// Sub machines, transition rates and the configuration itself are not dumped
+func (m *Machine) DumpState() ([]byte, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	dumped := dumpedState{
+		State:       m.currentState,
+		Previous:    m.previous,
+		EnteredAt:   m.enteredAt,
+		Paused:      m.isPaused,
+		Transitions: atomic.LoadUint64(&m.transitions),
+		Noops:       atomic.LoadUint64(&m.noops),
+		EventCounts: m.eventCounts,
+	}
+
+	timeouts := m.states[m.currentState].Timeouts
+	for _, armed := range m.timeouts {
+		if i := indexOf(timeouts, armed.timeout); i >= 0 {
+			dumped.Timeouts = append(dumped.Timeouts, dumpedTimeout{
+				Index:     i,
+				Remaining: m.remaining(armed),
+			})
+		}
+	}
+	for _, p := range m.paused {
+		if i := indexOf(timeouts, p.timeout); i >= 0 {
+			dumped.Timeouts = append(dumped.Timeouts, dumpedTimeout{
+				Index:     i,
+				Remaining: p.remaining,
+			})
+		}
+	}
+
+	for _, o := range m.timeline {
+		dumped.Timeline = append(dumped.Timeline, dumpedOccupancy{
+			State: o.state,
+			Start: o.start,
+			End:   o.end,
+		})
+	}
+
+	for _, rejection := range m.orderedRejections() {
+		dumped.Rejections = append(dumped.Rejections, dumpedRejection{
+			State:  rejection.State,
+			Event:  rejection.Event,
+			Reason: rejection.Reason.Error(),
+			At:     rejection.At,
+		})
+	}
+
+	return json.Marshal(dumped)
+}
+
+// LoadState creates a machine from the configuration and puts it into the
+// runtime captured by DumpState, the configuration should be the one of the
+// dumped machine. Loading is not a transition, so like Restore, no Entry,
+// EntryCtx, Always transition or StateChanged is run. The dumped timeouts are
+// armed for their remaining time, or held if the machine was paused, and a
+// MaxLifetime starts over. The timeline and the rejections are only kept if
+// the configuration records them. ErrStateNotFound is returned if the dump
+// refers to a state the configuration doesn't declare
+func LoadState(conf Config, data []byte) (*Machine, error) {
+	var dumped dumpedState
+	if err := json.Unmarshal(data, &dumped); err != nil {
+		return nil, err
+	}
+
+	compiled, err := compile(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	stateInfo, ok := compiled.states[dumped.State]
+	if !ok {
+		return nil, fmt.Errorf("state ref %d: %w", dumped.State, ErrStateNotFound)
+	}
+
+	// a dump of another configuration could refer to unknown states anywhere
+	refs := []State{dumped.Previous}
+	for _, o := range dumped.Timeline {
+		refs = append(refs, o.State)
+	}
+	for _, rejection := range dumped.Rejections {
+		refs = append(refs, rejection.State)
+	}
+	for _, ref := range refs {
+		if _, ok := compiled.states[ref]; !ok {
+			return nil, fmt.Errorf("dumped state ref %d: %w", ref, ErrStateNotFound)
+		}
+	}
+
+	for _, timeout := range dumped.Timeouts {
+		if timeout.Index < 0 || timeout.Index >= len(stateInfo.Timeouts) {
+			return nil, fmt.Errorf("state ref %d has no timeout %d: %w", dumped.State, timeout.Index, ErrInvalidTimeout)
+		}
+	}
+
+	m := compiled.machine()
+	m.bindSubMachines()
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.currentState = dumped.State
+	m.previous = dumped.Previous
+	m.enteredAt = dumped.EnteredAt
+	atomic.StoreUint64(&m.transitions, dumped.Transitions)
+	atomic.StoreUint64(&m.noops, dumped.Noops)
+	m.eventCounts = dumped.EventCounts
+
+	if m.recordTimeline {
+		for _, o := range dumped.Timeline {
+			m.timeline = append(m.timeline, occupancy{
+				state: o.State,
+				start: o.Start,
+				end:   o.End,
+			})
+		}
+	}
+
+	if m.rejectionLimit > 0 {
+		rejections := dumped.Rejections
+		if len(rejections) > m.rejectionLimit {
+			rejections = rejections[len(rejections)-m.rejectionLimit:]
+		}
+
+		for _, rejection := range rejections {
+			m.rejections = append(m.rejections, Rejection{
+				State:  rejection.State,
+				Event:  rejection.Event,
+				Reason: rejectionReason(rejection.Reason),
+				At:     rejection.At,
+			})
+		}
+	}
+
+	if stateInfo.SubMachine != nil {
+		stateInfo.SubMachine.reset()
+	}
+
+	m.isPaused = dumped.Paused
+	for _, timeout := range dumped.Timeouts {
+		if m.isPaused {
+			m.paused = append(m.paused, pausedTimeout{
+				timeout:   stateInfo.Timeouts[timeout.Index],
+				remaining: timeout.Remaining,
+			})
+			continue
+		}
+
+		m.armTimeoutFor(stateInfo.Timeouts[timeout.Index], timeout.Remaining)
+	}
+
+	m.armLifetime()
+
+	return m, nil
+}
+
+func indexOf(timeouts []*Timeout, timeout *Timeout) int {
+	for i, t := range timeouts {
+		if t == timeout {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// rejectionReason returns the error a rejection was dumped with
+func rejectionReason(reason string) error {
+	for _, err := range []error{ErrNoop, ErrCondFailed, ErrUnknownEvent} {
+		if err.Error() == reason {
+			return err
+		}
+	}
+
+	return ErrNoop
+}
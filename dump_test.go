@@ -0,0 +1,76 @@
+package fsm_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestDumpState(t *testing.T) {
+	clock := newFakeClock(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	conf := doorConfig()
+	conf.Clock = clock
+	conf.RecordTimeline = true
+	conf.RecordRejections = 5
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	for _, evt := range []fsm.Event{evtOpen, evtUnlock, evtClose} {
+		clock.Advance(time.Second)
+		door.Send(evt)
+	}
+
+	data, err := door.DumpState()
+	if err != nil {
+		t.Errorf("failed to dump the door: %s", err)
+		return
+	}
+
+	loaded, err := fsm.LoadState(conf, data)
+	if err != nil {
+		t.Errorf("failed to load the door: %s", err)
+		return
+	}
+	defer loaded.Stop()
+
+	if loaded.State() != closed {
+		t.Errorf("expected the loaded door to be closed, but got %d state", loaded.State())
+	}
+
+	if loaded.ToMermaidGantt() != door.ToMermaidGantt() {
+		t.Errorf("expected the timeline to survive, but got:\n%s\ninstead of:\n%s", loaded.ToMermaidGantt(), door.ToMermaidGantt())
+	}
+
+	if !reflect.DeepEqual(loaded.Rejections(), door.Rejections()) {
+		t.Errorf("expected rejections %v, but got %v", door.Rejections(), loaded.Rejections())
+	}
+
+	if loaded.TransitionCount() != 2 || loaded.EventCount(evtUnlock) != 1 || loaded.NoopCount() != 1 {
+		t.Errorf("expected the counters to survive, but got %d transitions, %d unlocks and %d noops", loaded.TransitionCount(), loaded.EventCount(evtUnlock), loaded.NoopCount())
+	}
+
+	if _, ok := loaded.CurrentTimeout(); !ok {
+		t.Errorf("expected the closed door's timeout to be armed")
+	}
+
+	for _, dump := range []string{
+		`{"state":42,"previous":1}`,
+		`{"state":1,"previous":42}`,
+		`{"state":1,"previous":1,"timeline":[{"state":42}]}`,
+		`{"state":1,"previous":1,"rejections":[{"state":42,"event":"open"}]}`,
+	} {
+		_, err = fsm.LoadState(conf, []byte(dump))
+		if !errors.Is(err, fsm.ErrStateNotFound) {
+			t.Errorf("in loading %s, expected %s error, but got %v", dump, fsm.ErrStateNotFound, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package fsm
+
+import "fmt"
+
+// AddState declares a new state with its transitions and an optional timeout on
+// a running machine, so machines can be extended by plugins. The new state only
+// has the given transitions, group and global transitions are not attached to it,
+// and it can only be reached once a transition targeting it is added with
+// AddTransition. The exporters and walkers see the state right away. Adding a
+// state doesn't affect other machines created from the same CompiledConfig.
+// The state is validated just like the ones of a Config, ErrInvalidState is
+// returned for ref 0 or Self, ErrDuplicateState if the state is already declared,
+// ErrStateNotFound if a target is unknown and ErrStopped once the machine is stopped
+func (m *Machine) AddState(ref State, on On, timeout *Timeout) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.stopped {
+		return ErrStopped
+	}
+
+	if err := validateRef(ref); err != nil {
+		return err
+	}
+
+	if _, ok := m.states[ref]; ok {
+		return fmt.Errorf("duplicate state ref %d: %w", ref, ErrDuplicateState)
+	}
+
+	stateInfo := &stateInfo{}
+	if timeout != nil {
+		stateInfo.Timeouts = []*Timeout{resolveSelfTimeout(ref, timeout)}
+	}
+
+	next := make(map[key]*stateEventInfo)
+	for _, nextState := range on {
+		k := key{ref, nextState.Event}
+		if _, ok := next[k]; !ok {
+			stateInfo.Events = append(stateInfo.Events, nextState.Event)
+		}
+		next[k] = newStateEventInfo(ref, nextState.Event, on)
+	}
+
+	if err := m.validateEdit(ref, stateInfo, next); err != nil {
+		return err
+	}
+
+	m.ownTables()
+	m.states[ref] = stateInfo
+	for k, stateEventInfo := range next {
+		m.nextStates[k] = stateEventInfo
+	}
+	m.order = append(m.order, ref)
+
+	return nil
+}
+
+// AddTransition adds the transitions to a declared state of a running machine,
+// a transition for an event the state already handles replaces it. Adding a
+// transition doesn't affect other machines created from the same CompiledConfig.
+// The transitions are validated just like the ones of a Config, ErrStateNotFound
+// is returned if the state or a target is unknown, ErrUnreachableTarget if a
+// target follows an unconditional one and ErrStopped once the machine is stopped
+func (m *Machine) AddTransition(from State, on On) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.stopped {
+		return ErrStopped
+	}
+
+	declared, ok := m.states[from]
+	if !ok {
+		return fmt.Errorf("state ref %d: %w", from, ErrStateNotFound)
+	}
+
+	// only the added transitions are validated, along with the state's own
+	// Always and timeouts which they don't change
+	added := *declared
+	added.Events = nil
+	next := make(map[key]*stateEventInfo)
+	for _, nextState := range on {
+		k := key{from, nextState.Event}
+		if _, ok := next[k]; !ok {
+			added.Events = append(added.Events, nextState.Event)
+		}
+		next[k] = newStateEventInfo(from, nextState.Event, on)
+	}
+
+	if err := m.validateEdit(from, &added, next); err != nil {
+		return err
+	}
+
+	m.ownTables()
+
+	stateInfo := m.states[from]
+	for _, evt := range added.Events {
+		k := key{from, evt}
+		if _, ok := m.nextStates[k]; !ok {
+			stateInfo.Events = append(stateInfo.Events, evt)
+		}
+		m.nextStates[k] = next[k]
+	}
+
+	return nil
+}
+
+// validateEdit runs the validation of build for the state as it would be after
+// an edit, with next holding the transitions of its events
+func (m *Machine) validateEdit(ref State, edited *stateInfo, next map[key]*stateEventInfo) error {
+	states := make(map[State]*stateInfo, len(m.states)+1)
+	for declared, stateInfo := range m.states {
+		states[declared] = stateInfo
+	}
+	states[ref] = edited
+
+	order := []State{ref}
+	errs := append(validateTargets(order, states, next), validateTimeouts(order, states)...)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	if err := validateLoops(states); err != nil {
+		return err
+	}
+
+	return validateUnreachable(order, states, next, m.warnings)
+}
+
+// RemoveTransition removes the transition of the given state for the event, it
+// returns false if there was none. Unlike SetTransitionEnabled, the transition is
+// gone from the exporters too and can only come back with AddTransition. Removing
+// a transition doesn't affect other machines created from the same CompiledConfig
+func (m *Machine) RemoveTransition(from State, evt Event) bool {
+	m.mu.Lock()
+	defer m.unlock()
+
+	k := key{from, evt}
+	if _, ok := m.nextStates[k]; !ok {
+		return false
+	}
+
+	m.ownTables()
+	delete(m.nextStates, k)
+
+	stateInfo := m.states[from]
+	for i, declared := range stateInfo.Events {
+		if declared == evt {
+			stateInfo.Events = append(stateInfo.Events[:i], stateInfo.Events[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// newStateEventInfo builds the transition of the state for the event, the last declared one wins
+func newStateEventInfo(ref State, evt Event, on On) *stateEventInfo {
+	var info *stateEventInfo
+	for _, nextState := range on {
+		if nextState.Event != evt {
+			continue
+		}
+
+		info = &stateEventInfo{
+			Cond:     nextState.Cond,
+			CondCtx:  nextState.CondCtx,
+			Cached:   nextState.Cached,
+			Schedule: nextState.Schedule,
+			Count:    nextState.Count,
+			Targets:  resolveSelf(ref, nextState.Targets),
+			Label:    nextState.Label,
+			Color:    nextState.Color,
+			Pattern:  nextState.Pattern,
+		}
+	}
+
+	return info
+}
+
+// ownTables copies the lookup tables before they are changed for the first
+// time, as machines created from the same CompiledConfig share them
+func (m *Machine) ownTables() {
+	if m.ownsTables {
+		return
+	}
+
+	states := make(map[State]*stateInfo, len(m.states))
+	for ref, stateInfo := range m.states {
+		copied := *stateInfo
+		copied.Events = append([]Event(nil), stateInfo.Events...)
+		states[ref] = &copied
+	}
+
+	nextStates := make(map[key]*stateEventInfo, len(m.nextStates))
+	for k, stateEventInfo := range m.nextStates {
+		nextStates[k] = stateEventInfo
+	}
+
+	m.states = states
+	m.nextStates = nextStates
+	m.order = append([]State(nil), m.order...)
+	m.ownsTables = true
+}
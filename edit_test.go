@@ -0,0 +1,321 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestAddState(t *testing.T) {
+	const (
+		maintenance = locked + 1
+
+		evtMaintain = fsm.Event("maintain")
+		evtRepair   = fsm.Event("repair")
+	)
+
+	compiled, err := fsm.CompileConfig(doorConfig())
+	if err != nil {
+		t.Errorf("failed to compile door config: %s", err)
+		return
+	}
+
+	door, err := fsm.NewFromCompiled(compiled)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	other, err := fsm.NewFromCompiled(compiled)
+	if err != nil {
+		t.Errorf("failed to create other door fsm: %s", err)
+		return
+	}
+	defer other.Stop()
+
+	err = door.AddState(maintenance, fsm.On{
+		{
+			Event: evtRepair,
+			Targets: fsm.Targets{
+				{
+					Target: closed,
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Errorf("failed to add maintenance state: %s", err)
+		return
+	}
+
+	err = door.AddTransition(closed, fsm.On{
+		{
+			Event: evtMaintain,
+			Targets: fsm.Targets{
+				{
+					Target: maintenance,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to add maintain transition: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description   string
+		event         fsm.Event
+		expectedState fsm.State
+	}{
+		{
+			description:   "maintaining the closed door",
+			event:         evtMaintain,
+			expectedState: maintenance,
+		},
+		{
+			description:   "repairing the door",
+			event:         evtRepair,
+			expectedState: closed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err = door.Send(testCase.event)
+		if err != nil {
+			t.Errorf("in %s, failed to send %s: %s", testCase.description, testCase.event, err)
+		}
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+	}
+
+	err = other.Send(evtMaintain)
+	if err != fsm.ErrNoop {
+		t.Errorf("expected a door from the same compiled config to be unaffected, but got %v", err)
+	}
+
+	err = door.AddState(closed, nil, nil)
+	if !errors.Is(err, fsm.ErrDuplicateState) {
+		t.Errorf("expected %s error adding a declared state, but got %v", fsm.ErrDuplicateState, err)
+	}
+
+	err = door.AddState(maintenance+1, fsm.On{
+		{
+			Event: evtRepair,
+			Targets: fsm.Targets{
+				{
+					Target: maintenance + 2,
+				},
+			},
+		},
+	}, nil)
+	if !errors.Is(err, fsm.ErrStateNotFound) {
+		t.Errorf("expected %s error adding a state with an unknown target, but got %v", fsm.ErrStateNotFound, err)
+	}
+}
+
+func TestRemoveTransition(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	if !door.RemoveTransition(unlocked, evtOpen) {
+		t.Errorf("expected the open transition of the unlocked door to be removed")
+	}
+
+	if door.RemoveTransition(unlocked, evtOpen) {
+		t.Errorf("expected nothing to be removed the second time")
+	}
+
+	for _, evt := range []fsm.Event{evtLock, evtUnlock} {
+		err = door.Send(evt)
+		if err != nil {
+			t.Errorf("failed to send %s: %s", evt, err)
+		}
+	}
+
+	events := door.AllowedEvents()
+	for _, evt := range events {
+		if evt == evtOpen {
+			t.Errorf("expected open to be gone from the allowed events, but got %v", events)
+		}
+	}
+
+	err = door.Send(evtOpen)
+	if err != fsm.ErrNoop {
+		t.Errorf("expected %s error opening the unlocked door, but got %v", fsm.ErrNoop, err)
+	}
+
+	if door.State() != unlocked {
+		t.Errorf("expected %d state but got %d", unlocked, door.State())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	conf := doorConfig()
+	conf.StrictReachability = true
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	err = door.Validate()
+	if err != nil {
+		t.Errorf("expected the door to be valid, but got %s", err)
+	}
+
+	// the locked door is the only way to the unlocked one
+	door.RemoveTransition(locked, evtUnlock)
+
+	err = door.Validate()
+	if !errors.Is(err, fsm.ErrUnreachableState) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrUnreachableState, err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	conf := doorConfig()
+	if errs := conf.Validate(); len(errs) != 0 {
+		t.Errorf("expected the door config to be valid, but got %v", errs)
+		return
+	}
+
+	conf.States = append(conf.States, fsm.States{
+		{Ref: closed},
+		{
+			Ref: 10,
+			On: fsm.On{
+				{Event: evtOpen, Targets: fsm.Targets{{Target: 11}}},
+			},
+			Timeout: &fsm.Timeout{Targets: fsm.Targets{{Target: closed}}},
+		},
+	}...)
+
+	errs := conf.Validate()
+	if len(errs) != 3 {
+		t.Errorf("expected 3 errors, but got %v", errs)
+		return
+	}
+
+	for i, expected := range []error{fsm.ErrDuplicateState, fsm.ErrStateNotFound, fsm.ErrInvalidTimeout} {
+		if !errors.Is(errs[i], expected) {
+			t.Errorf("expected error %d to be %s, but got %s", i, expected, errs[i])
+		}
+	}
+
+	_, err := fsm.NewMachine(conf)
+	if !errors.Is(err, fsm.ErrDuplicateState) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrDuplicateState, err)
+	}
+}
+
+func TestEditValidation(t *testing.T) {
+	const (
+		maintenance = locked + 1
+
+		evtMaintain = fsm.Event("maintain")
+	)
+
+	pass := func() bool { return true }
+
+	testCases := []struct {
+		description string
+		edit        func(door *fsm.Machine) error
+		expectedErr error
+	}{
+		{
+			description: "adding state 0",
+			edit: func(door *fsm.Machine) error {
+				return door.AddState(0, nil, nil)
+			},
+			expectedErr: fsm.ErrInvalidState,
+		},
+		{
+			description: "adding the Self state",
+			edit: func(door *fsm.Machine) error {
+				return door.AddState(fsm.Self, nil, nil)
+			},
+			expectedErr: fsm.ErrInvalidState,
+		},
+		{
+			description: "adding a state with an unreachable target",
+			edit: func(door *fsm.Machine) error {
+				return door.AddState(maintenance, fsm.On{
+					{Event: evtMaintain, Targets: fsm.Targets{{Target: closed}, {Target: opened}}},
+				}, nil)
+			},
+			expectedErr: fsm.ErrUnreachableTarget,
+		},
+		{
+			description: "adding a state with a zero duration timeout",
+			edit: func(door *fsm.Machine) error {
+				return door.AddState(maintenance, nil, &fsm.Timeout{Targets: fsm.Targets{{Target: closed}}})
+			},
+			expectedErr: fsm.ErrInvalidTimeout,
+		},
+		{
+			description: "adding a transition with an unreachable target",
+			edit: func(door *fsm.Machine) error {
+				return door.AddTransition(closed, fsm.On{
+					{Event: evtMaintain, Targets: fsm.Targets{{Target: opened}, {Target: locked}}},
+				})
+			},
+			expectedErr: fsm.ErrUnreachableTarget,
+		},
+		{
+			description: "adding a transition with an unknown target",
+			edit: func(door *fsm.Machine) error {
+				return door.AddTransition(closed, fsm.On{
+					{Event: evtMaintain, Targets: fsm.Targets{{Target: maintenance}}},
+				})
+			},
+			expectedErr: fsm.ErrStateNotFound,
+		},
+		{
+			description: "adding a guarded self transition",
+			edit: func(door *fsm.Machine) error {
+				return door.AddTransition(closed, fsm.On{
+					{Event: evtMaintain, Targets: fsm.Targets{{Target: fsm.Self, Cond: pass}, {Target: opened}}},
+				})
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		door, err := fsm.NewMachine(doorConfig())
+		if err != nil {
+			t.Errorf("in %s, failed to create door fsm: %s", testCase.description, err)
+			continue
+		}
+
+		err = testCase.edit(door)
+		if !errors.Is(err, testCase.expectedErr) {
+			t.Errorf("in %s, expected %v error, but got %v", testCase.description, testCase.expectedErr, err)
+		}
+
+		err = door.Send(evtMaintain)
+		if testCase.expectedErr != nil && err != fsm.ErrNoop {
+			t.Errorf("in %s, expected the rejected edit to leave the door as is, but got %v", testCase.description, err)
+		}
+
+		door.Stop()
+	}
+
+	_, err := fsm.NewMachine(fsm.Config{
+		Initial: closed,
+		States:  fsm.States{{Ref: closed}, {Ref: fsm.Self}},
+	})
+	if !errors.Is(err, fsm.ErrInvalidState) {
+		t.Errorf("expected %s error declaring the Self state, but got %v", fsm.ErrInvalidState, err)
+	}
+}
@@ -0,0 +1,45 @@
+package fsm
+
+// SetTransitionEnabled turns the transition of the given state for the event off
+// and back on at runtime without changing the configuration, a disabled transition
+// behaves as if it wasn't declared. The exporters still show it
+func (m *Machine) SetTransitionEnabled(from State, evt Event, enabled bool) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	k := key{from, evt}
+	if enabled {
+		delete(m.disabled, k)
+		return
+	}
+
+	if m.disabled == nil {
+		m.disabled = make(map[key]bool)
+	}
+	m.disabled[k] = true
+}
+
+// transition returns the enabled transition for the state and event along with
+// the event it is declared for, if the state has none for the event itself, the
+// first declared pattern matching the event is used
+func (m *Machine) transition(k key) (*stateEventInfo, Event, bool) {
+	stateEventInfo, ok := m.nextStates[k]
+	if ok && !m.disabled[k] {
+		return stateEventInfo, k.Event, true
+	}
+
+	stateInfo, found := m.states[k.Ref]
+	if ok || !found {
+		return nil, "", false
+	}
+
+	for _, pattern := range stateInfo.Events {
+		patternKey := key{k.Ref, pattern}
+		stateEventInfo := m.nextStates[patternKey]
+		if stateEventInfo.Pattern && k.Event.Match(pattern) && !m.disabled[patternKey] {
+			return stateEventInfo, pattern, true
+		}
+	}
+
+	return nil, "", false
+}
@@ -0,0 +1,83 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestSetTransitionEnabled(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	door.SetTransitionEnabled(closed, evtOpen, false)
+
+	events := door.AllowedEvents()
+	if len(events) != 1 || events[0] != evtLock {
+		t.Errorf("expected only %s to be allowed, but got %v", evtLock, events)
+	}
+
+	testCases := []struct {
+		description   string
+		enableOpen    bool
+		event         fsm.Event
+		sendError     error
+		expectedState fsm.State
+	}{
+		{
+			description:   "opening with the transition disabled",
+			event:         evtOpen,
+			sendError:     fsm.ErrNoop,
+			expectedState: closed,
+		},
+		{
+			description:   "locking still works",
+			event:         evtLock,
+			sendError:     nil,
+			expectedState: locked,
+		},
+		{
+			description:   "unlocking",
+			event:         evtUnlock,
+			sendError:     nil,
+			expectedState: unlocked,
+		},
+		{
+			description:   "opening the unlocked door isn't affected",
+			event:         evtOpen,
+			sendError:     nil,
+			expectedState: opened,
+		},
+		{
+			description:   "closing the door",
+			event:         evtClose,
+			sendError:     nil,
+			expectedState: closed,
+		},
+		{
+			description:   "opening with the transition enabled again",
+			enableOpen:    true,
+			event:         evtOpen,
+			sendError:     nil,
+			expectedState: opened,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.enableOpen {
+			door.SetTransitionEnabled(closed, evtOpen, true)
+		}
+
+		err = door.Send(testCase.event)
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %v, but got %v error", testCase.description, testCase.sendError, err)
+		}
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Eventf formats a compound event, such as "key:enter" out of a kind and its
+// argument, events are routed on their full string like any other event
+func Eventf(format string, args ...interface{}) Event {
+	return Event(fmt.Sprintf(format, args...))
+}
+
+// Match reports whether the event matches the pattern, a pattern ending with *
+// matches every event starting with the rest of it, so "key:*" matches both
+// "key:enter" and "key:esc", any other pattern only matches itself. A transition
+// declared with On.Pattern handles the matching events which the state has no
+// transition of their own for, the first declared matching pattern wins
+func (e Event) Match(pattern Event) bool {
+	if !strings.HasSuffix(string(pattern), "*") {
+		return e == pattern
+	}
+
+	return strings.HasPrefix(string(e), string(pattern[:len(pattern)-1]))
+}
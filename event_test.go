@@ -0,0 +1,188 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestCompoundEvents(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		editing
+		submitted
+		canceled
+		typing
+	)
+
+	keyOn := func(key string, target fsm.State) fsm.On {
+		return fsm.On{
+			{
+				Event:   fsm.Eventf("key:%s", key),
+				Pattern: key == "*",
+				Targets: fsm.Targets{
+					{
+						Target: target,
+					},
+				},
+			},
+		}
+	}
+
+	var on fsm.On
+	on = append(on, keyOn("*", typing)...)
+	on = append(on, keyOn("enter", submitted)...)
+	on = append(on, keyOn("esc", canceled)...)
+
+	testCases := []struct {
+		description   string
+		event         fsm.Event
+		expectedState fsm.State
+	}{
+		{
+			description:   "pressing enter",
+			event:         fsm.Eventf("key:%s", "enter"),
+			expectedState: submitted,
+		},
+		{
+			description:   "pressing esc",
+			event:         "key:esc",
+			expectedState: canceled,
+		},
+		{
+			description:   "pressing any other key",
+			event:         "key:a",
+			expectedState: typing,
+		},
+	}
+
+	for _, testCase := range testCases {
+		input, err := fsm.NewMachine(fsm.Config{
+			Initial: editing,
+			States: fsm.States{
+				{Ref: editing, On: on},
+				{Ref: submitted},
+				{Ref: canceled},
+				{Ref: typing},
+			},
+		})
+		if err != nil {
+			t.Errorf("in %s, failed to create input fsm: %s", testCase.description, err)
+			continue
+		}
+
+		err = input.Send(testCase.event)
+		if err != nil {
+			t.Errorf("in %s, unexpected error: %s", testCase.description, err)
+		}
+
+		if input.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, input.State())
+		}
+
+		input.Stop()
+	}
+
+	if !fsm.Event("key:enter").Match("key:*") || fsm.Event("mouse:click").Match("key:*") {
+		t.Errorf("expected key:* to match key events only")
+	}
+}
+
+func TestLiteralPatternEvents(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		editing
+		typing
+	)
+
+	input, err := fsm.NewMachine(fsm.Config{
+		Initial: editing,
+		States: fsm.States{
+			{
+				Ref: editing,
+				On: fsm.On{
+					{
+						Event: "key:*",
+						Targets: fsm.Targets{
+							{
+								Target: typing,
+							},
+						},
+					},
+				},
+			},
+			{Ref: typing},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to create input fsm: %s", err)
+		return
+	}
+	defer input.Stop()
+
+	err = input.Send("key:a")
+	if !errors.Is(err, fsm.ErrNoop) {
+		t.Errorf("expected key:a to be undefined without Pattern but got %v", err)
+	}
+
+	err = input.Send("key:*")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if input.State() != typing {
+		t.Errorf("expected %d state but got %d", typing, input.State())
+	}
+}
+
+func TestCountPatternEvents(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		editing
+		typing
+	)
+
+	input, err := fsm.NewMachine(fsm.Config{
+		Initial: editing,
+		States: fsm.States{
+			{
+				Ref: editing,
+				On: fsm.On{
+					{
+						Event:   "key:*",
+						Pattern: true,
+						Count:   3,
+						Targets: fsm.Targets{
+							{
+								Target: typing,
+							},
+						},
+					},
+				},
+			},
+			{Ref: typing},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to create input fsm: %s", err)
+		return
+	}
+	defer input.Stop()
+
+	for _, evt := range []fsm.Event{"key:a", "key:b"} {
+		err = input.Send(evt)
+		if !errors.Is(err, fsm.ErrCounting) {
+			t.Errorf("expected %s to be counted but got %v", evt, err)
+		}
+	}
+
+	err = input.Send("key:c")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if input.State() != typing {
+		t.Errorf("expected %d state but got %d", typing, input.State())
+	}
+}
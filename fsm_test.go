@@ -1,8 +1,11 @@
 package fsm_test
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -417,3 +420,998 @@ func TestExampleDoor(t *testing.T) {
 		}
 	}
 }
+
+func TestTimeoutNoMatch(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		polling
+		ready
+	)
+
+	testCases := []struct {
+		description    string
+		onNoMatch      fsm.NoMatch
+		expectedState  fsm.State
+		expectedChecks int32
+		expectedCalls  int32
+	}{
+		{
+			description:    "re-arming the timeout until the guard passes",
+			onNoMatch:      fsm.ReArm,
+			expectedState:  ready,
+			expectedChecks: 3,
+		},
+		{
+			description:    "staying in the state once the guard fails",
+			onNoMatch:      fsm.Stay,
+			expectedState:  polling,
+			expectedChecks: 1,
+		},
+		{
+			description:    "calling the fallback once the guard fails",
+			onNoMatch:      fsm.CallFallback,
+			expectedState:  polling,
+			expectedChecks: 1,
+			expectedCalls:  1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var checks, calls int32
+
+		m, err := fsm.NewMachine(fsm.Config{
+			Initial: polling,
+			States: fsm.States{
+				{
+					Ref: polling,
+					Timeout: &fsm.Timeout{
+						Duration:  20 * time.Millisecond,
+						OnNoMatch: testCase.onNoMatch,
+						Fallback: func() {
+							atomic.AddInt32(&calls, 1)
+						},
+						Targets: fsm.Targets{
+							{
+								Cond: func() bool {
+									return atomic.AddInt32(&checks, 1) > 2
+								},
+								Target: ready,
+							},
+						},
+					},
+				},
+				{
+					Ref: ready,
+				},
+			},
+		})
+
+		if err != nil {
+			t.Errorf("in %s, failed to initialized machine: %s", testCase.description, err)
+			continue
+		}
+
+		waitFor(300*time.Millisecond, func() bool {
+			return m.State() == ready
+		})
+
+		// give a chance to a wrongly re-armed timeout to fire
+		time.Sleep(100 * time.Millisecond)
+
+		if m.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, m.State())
+		}
+
+		if got := atomic.LoadInt32(&checks); got != testCase.expectedChecks {
+			t.Errorf("in %s, expected guard to be checked %d times, but got %d", testCase.description, testCase.expectedChecks, got)
+		}
+
+		if got := atomic.LoadInt32(&calls); got != testCase.expectedCalls {
+			t.Errorf("in %s, expected fallback to be called %d times, but got %d", testCase.description, testCase.expectedCalls, got)
+		}
+	}
+}
+
+func TestMultipleTimeouts(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		waiting
+		escalated
+	)
+
+	var mu sync.Mutex
+	fired := make([]string, 0)
+	record := func(name string) func() {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			fired = append(fired, name)
+		}
+	}
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial: waiting,
+		States: fsm.States{
+			{
+				Ref: waiting,
+				Timeouts: []*fsm.Timeout{
+					{
+						Duration: 100 * time.Millisecond,
+						Action:   record("warn"),
+					},
+					{
+						Duration: 300 * time.Millisecond,
+						Action:   record("escalate"),
+						Targets: fsm.Targets{
+							{
+								Target: escalated,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: escalated,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	if !waitFor(time.Second, func() bool { return m.State() == escalated }) {
+		t.Errorf("expected %d state but got %d", escalated, m.State())
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []string{"warn", "escalate"}
+	if len(fired) != len(expected) {
+		t.Errorf("expected %v to fire, but got %v", expected, fired)
+		return
+	}
+
+	for i, value := range expected {
+		if fired[i] != value {
+			t.Errorf("expected %s, but got %s at %d iteration", value, fired[i], i)
+		}
+	}
+}
+
+func TestTransientInitialState(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		booting
+		ready
+		failed
+	)
+
+	entered := make([]fsm.State, 0)
+	changes := make([]string, 0)
+	healthy := true
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial: booting,
+		StateChanged: func(prev fsm.State, next fsm.State) {
+			changes = append(changes, fmt.Sprintf("%d->%d", prev, next))
+		},
+		States: fsm.States{
+			{
+				Ref: booting,
+				Entry: func() {
+					entered = append(entered, booting)
+				},
+				Always: fsm.Targets{
+					{
+						Cond: func() bool {
+							return healthy
+						},
+						Target: ready,
+					},
+					{
+						Target: failed,
+					},
+				},
+			},
+			{
+				Ref: ready,
+				Entry: func() {
+					entered = append(entered, ready)
+				},
+			},
+			{
+				Ref: failed,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	if m.State() != ready {
+		t.Errorf("expected machine to settle in %d state but got %d", ready, m.State())
+	}
+
+	expectedEntered := []fsm.State{booting, ready}
+	if fmt.Sprint(entered) != fmt.Sprint(expectedEntered) {
+		t.Errorf("expected %v states to be entered, but got %v", expectedEntered, entered)
+	}
+
+	expectedChanges := []string{fmt.Sprintf("%d->%d", booting, ready)}
+	if fmt.Sprint(changes) != fmt.Sprint(expectedChanges) {
+		t.Errorf("expected %v state changes, but got %v", expectedChanges, changes)
+	}
+}
+
+func TestAlwaysLoop(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		ping
+		pong
+	)
+
+	_, err := fsm.NewMachine(fsm.Config{
+		Initial: ping,
+		States: fsm.States{
+			{
+				Ref: ping,
+				Always: fsm.Targets{
+					{
+						Target: pong,
+					},
+				},
+			},
+			{
+				Ref: pong,
+				Always: fsm.Targets{
+					{
+						Target: ping,
+					},
+				},
+			},
+		},
+	})
+
+	if !errors.Is(err, fsm.ErrTransitionLoop) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrTransitionLoop, err)
+	}
+}
+
+func TestGuardedAlwaysLoop(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		idle
+		ping
+		pong
+	)
+
+	const evtStart = fsm.Event("start")
+
+	always := func() bool {
+		return true
+	}
+
+	conf := fsm.Config{
+		Initial: idle,
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event:   evtStart,
+						Targets: fsm.Targets{{Target: ping}},
+					},
+				},
+			},
+			{
+				Ref:    ping,
+				Always: fsm.Targets{{Cond: always, Target: pong}},
+			},
+			{
+				Ref:    pong,
+				Always: fsm.Targets{{Cond: always, Target: ping}},
+			},
+		},
+	}
+
+	machine, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create fsm: %s", err)
+		return
+	}
+	defer machine.Stop()
+
+	err = machine.Send(evtStart)
+	if !errors.Is(err, fsm.ErrTransitionLoop) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrTransitionLoop, err)
+	}
+
+	conf.Initial = ping
+	_, err = fsm.NewMachine(conf)
+	if !errors.Is(err, fsm.ErrTransitionLoop) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrTransitionLoop, err)
+	}
+}
+
+func TestConditionalTimeout(t *testing.T) {
+	testCases := []struct {
+		description   string
+		security      bool
+		expectedState fsm.State
+	}{
+		{
+			description:   "door doesn't lock itself when security is disabled",
+			security:      false,
+			expectedState: closed,
+		},
+		{
+			description:   "door locks itself when security is enabled",
+			security:      true,
+			expectedState: locked,
+		},
+	}
+
+	for _, testCase := range testCases {
+		security := testCase.security
+
+		conf := doorConfig()
+		conf.States[0].Timeout.Duration = 20 * time.Millisecond
+		conf.States[0].Timeout.Cond = func() bool {
+			return security
+		}
+
+		door, err := fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("in %s, failed to create door fsm: %s", testCase.description, err)
+			continue
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+	}
+}
+
+func TestSendTimed(t *testing.T) {
+	conf := doorConfig()
+	conf.States[0].On[1].Cond = func() bool {
+		time.Sleep(20 * time.Millisecond)
+		return true
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	elapsed, err := door.SendTimed(evtOpen)
+	if err != nil {
+		t.Errorf("failed to send open: %s", err)
+		return
+	}
+
+	if elapsed < 20*time.Millisecond {
+		t.Errorf("expected send to take at least 20ms, but got %s", elapsed)
+	}
+}
+
+func TestCountedTransition(t *testing.T) {
+	const (
+		EvtFail    = fsm.Event("fail")
+		EvtSucceed = fsm.Event("succeed")
+	)
+
+	const (
+		_ fsm.State = iota
+		loggedOut
+		loggedIn
+		lockedOut
+	)
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial: loggedOut,
+		States: fsm.States{
+			{
+				Ref: loggedOut,
+				On: fsm.On{
+					{
+						Event: EvtFail,
+						Count: 3,
+						Targets: fsm.Targets{
+							{
+								Target: lockedOut,
+							},
+						},
+					},
+					{
+						Event: EvtSucceed,
+						Targets: fsm.Targets{
+							{
+								Target: loggedIn,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: loggedIn,
+				On: fsm.On{
+					{
+						Event: EvtFail,
+						Targets: fsm.Targets{
+							{
+								Target: loggedOut,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: lockedOut,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description   string
+		event         fsm.Event
+		sendError     error
+		expectedState fsm.State
+	}{
+		{
+			description:   "first failure",
+			event:         EvtFail,
+			sendError:     fsm.ErrCounting,
+			expectedState: loggedOut,
+		},
+		{
+			description:   "second failure",
+			event:         EvtFail,
+			sendError:     fsm.ErrCounting,
+			expectedState: loggedOut,
+		},
+		{
+			description:   "logging in resets the failures",
+			event:         EvtSucceed,
+			sendError:     nil,
+			expectedState: loggedIn,
+		},
+		{
+			description:   "logging out",
+			event:         EvtFail,
+			sendError:     nil,
+			expectedState: loggedOut,
+		},
+		{
+			description:   "first failure after logging out",
+			event:         EvtFail,
+			sendError:     fsm.ErrCounting,
+			expectedState: loggedOut,
+		},
+		{
+			description:   "second failure after logging out",
+			event:         EvtFail,
+			sendError:     fsm.ErrCounting,
+			expectedState: loggedOut,
+		},
+		{
+			description:   "third failure locks the account",
+			event:         EvtFail,
+			sendError:     nil,
+			expectedState: lockedOut,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err = m.Send(testCase.event)
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %s, but got %s error", testCase.description, testCase.sendError, err)
+		}
+
+		if m.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, m.State())
+		}
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	var pings int32
+
+	conf := doorConfig()
+	conf.States[0].Timeout = &fsm.Timeout{
+		Duration: 20 * time.Millisecond,
+		Action: func() {
+			atomic.AddInt32(&pings, 1)
+		},
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&pings); got != 1 {
+		t.Errorf("expected action to run once, but got %d runs", got)
+	}
+
+	if door.State() != closed {
+		t.Errorf("expected %d state but got %d", closed, door.State())
+	}
+}
+
+func TestCacheGuards(t *testing.T) {
+	testCases := []struct {
+		description   string
+		cacheGuards   bool
+		expectedCalls int
+	}{
+		{
+			description:   "guard runs for every reference without caching",
+			cacheGuards:   false,
+			expectedCalls: 4,
+		},
+		{
+			description:   "guard runs once per send with caching",
+			cacheGuards:   true,
+			expectedCalls: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		calls := 0
+		guard := func() bool {
+			calls++
+			return true
+		}
+
+		conf := doorConfig()
+		conf.CacheGuards = testCase.cacheGuards
+		conf.States[0].On[1].Cond = guard
+		conf.States[0].On[1].Targets[0].Cond = guard
+		conf.States[3].On[0].Cond = guard
+		conf.States[3].On[0].Targets[0].Cond = guard
+
+		door, err := fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("in %s, failed to create door fsm: %s", testCase.description, err)
+			continue
+		}
+
+		door.Send(evtOpen)
+		door.Send(evtClose)
+
+		if door.State() != closed {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, closed, door.State())
+		}
+
+		if calls != testCase.expectedCalls {
+			t.Errorf("in %s, expected guard to run %d times, but got %d", testCase.description, testCase.expectedCalls, calls)
+		}
+	}
+}
+
+func TestCacheGuardsClosures(t *testing.T) {
+	other, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create other door fsm: %s", err)
+		return
+	}
+	defer other.Stop()
+
+	// both guards are closures of the same function literal
+	conf := doorConfig()
+	conf.CacheGuards = true
+	conf.States[0].On[1].Targets = fsm.Targets{
+		{Target: locked, Cond: fsm.InState(other, opened)},
+		{Target: opened, Cond: fsm.InState(other, closed)},
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	err = door.Send(evtOpen)
+	if err != nil {
+		t.Errorf("failed to send %s: %s", evtOpen, err)
+	}
+
+	if door.State() != opened {
+		t.Errorf("expected %d state but got %d", opened, door.State())
+	}
+}
+
+func TestUnreachableTarget(t *testing.T) {
+	conf := doorConfig()
+	conf.States[0].Timeout.Targets = fsm.Targets{
+		{
+			Target: locked,
+		},
+		{
+			Target: opened,
+		},
+	}
+
+	_, err := fsm.NewMachine(conf)
+	if !errors.Is(err, fsm.ErrUnreachableTarget) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrUnreachableTarget, err)
+	}
+
+	var warnings []error
+	conf.Warnings = func(err error) {
+		warnings = append(warnings, err)
+	}
+
+	_, err = fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("expected machine to be created with warnings, but got %s", err)
+	}
+
+	if len(warnings) != 1 || !errors.Is(warnings[0], fsm.ErrUnreachableTarget) {
+		t.Errorf("expected a single %s warning, but got %v", fsm.ErrUnreachableTarget, warnings)
+	}
+}
+
+func TestExpectContiguousStates(t *testing.T) {
+	base := unlocked
+
+	conf := doorConfig()
+	conf.ExpectContiguousFrom = &base
+
+	_, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("expected contiguous states to be accepted, but got %s", err)
+	}
+
+	conf.States = append(conf.States, conf.States[1])
+	conf.States[len(conf.States)-1].Ref = locked + 3
+
+	_, err = fsm.NewMachine(conf)
+	if !errors.Is(err, fsm.ErrNonContiguousStates) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrNonContiguousStates, err)
+		return
+	}
+
+	gap := fmt.Sprintf("%d-%d", locked+1, locked+2)
+	if !strings.Contains(err.Error(), gap) {
+		t.Errorf("expected the error to list the %s gap, but got %s", gap, err)
+	}
+}
+
+func TestStrictSend(t *testing.T) {
+	testCases := []struct {
+		description string
+		strict      bool
+		event       fsm.Event
+		sendError   error
+	}{
+		{
+			description: "lenient machine ignores an empty event",
+			strict:      false,
+			event:       "",
+			sendError:   fsm.ErrNoop,
+		},
+		{
+			description: "strict machine rejects an empty event",
+			strict:      true,
+			event:       "",
+			sendError:   fsm.ErrUnknownEvent,
+		},
+		{
+			description: "strict machine still toggles",
+			strict:      true,
+			event:       evtToggle,
+			sendError:   nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		conf := trafficLightConfig(time.Hour)
+		conf.StrictSend = testCase.strict
+
+		light, err := fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("in %s, failed to create traffic light fsm: %s", testCase.description, err)
+			continue
+		}
+
+		err = light.Send(testCase.event)
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %v, but got %v error", testCase.description, testCase.sendError, err)
+		}
+	}
+}
+
+func TestInvalidTimeout(t *testing.T) {
+	testCases := []struct {
+		description string
+		duration    time.Duration
+		immediate   bool
+		expectedErr error
+	}{
+		{
+			description: "zero duration",
+			duration:    0,
+			expectedErr: fsm.ErrInvalidTimeout,
+		},
+		{
+			description: "negative duration",
+			duration:    -time.Second,
+			expectedErr: fsm.ErrInvalidTimeout,
+		},
+		{
+			description: "immediate with a duration",
+			duration:    time.Second,
+			immediate:   true,
+			expectedErr: fsm.ErrInvalidTimeout,
+		},
+		{
+			description: "explicit immediate",
+			duration:    0,
+			immediate:   true,
+			expectedErr: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		conf := doorConfig()
+		conf.States[0].Timeout.Duration = testCase.duration
+		conf.States[0].Timeout.Immediate = testCase.immediate
+
+		door, err := fsm.NewMachine(conf)
+		if !errors.Is(err, testCase.expectedErr) {
+			t.Errorf("in %s, expected %v error, but got %v", testCase.description, testCase.expectedErr, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		ok := waitFor(time.Second, func() bool {
+			return door.State() == locked
+		})
+		if !ok {
+			t.Errorf("in %s, expected the door to lock right away, but got %d", testCase.description, door.State())
+		}
+	}
+}
+
+func TestEventAliases(t *testing.T) {
+	conf := doorConfig()
+	conf.EventAliases = map[fsm.Event]fsm.Event{
+		"push":    evtOpen,
+		"swing":   evtOpen,
+		"slam":    evtClose,
+		"unbolt":  evtUnlock,
+		"padlock": evtLock,
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description   string
+		event         fsm.Event
+		expectedState fsm.State
+	}{
+		{
+			description:   "pushing the closed door",
+			event:         "push",
+			expectedState: opened,
+		},
+		{
+			description:   "slamming the opened door",
+			event:         "slam",
+			expectedState: closed,
+		},
+		{
+			description:   "swinging the closed door",
+			event:         "swing",
+			expectedState: opened,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err = door.Send(testCase.event)
+		if err != nil {
+			t.Errorf("in %s, unexpected error: %s", testCase.description, err)
+		}
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+	}
+
+	conf.EventAliases["kick"] = "break"
+
+	_, err = fsm.NewMachine(conf)
+	if !errors.Is(err, fsm.ErrUnknownEvent) {
+		t.Errorf("expected %s error for an alias of an unhandled event, but got %v", fsm.ErrUnknownEvent, err)
+	}
+}
+
+func TestTargetSelection(t *testing.T) {
+	always := func() bool { return true }
+
+	testCases := []struct {
+		description   string
+		selection     fsm.TargetSelection
+		sendError     error
+		expectedState fsm.State
+	}{
+		{
+			description:   "first match takes the first passing target",
+			selection:     fsm.FirstMatch,
+			sendError:     nil,
+			expectedState: opened,
+		},
+		{
+			description:   "exactly one rejects overlapping guards",
+			selection:     fsm.ExactlyOne,
+			sendError:     fsm.ErrAmbiguousTarget,
+			expectedState: closed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		conf := doorConfig()
+		conf.TargetSelection = testCase.selection
+		conf.States[0].On[1].Targets = fsm.Targets{
+			{
+				Cond:   always,
+				Target: opened,
+			},
+			{
+				Cond:   always,
+				Target: locked,
+			},
+		}
+
+		door, err := fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("in %s, failed to create door fsm: %s", testCase.description, err)
+			continue
+		}
+
+		err = door.Send(evtOpen)
+		if !errors.Is(err, testCase.sendError) {
+			t.Errorf("in %s, expect to %v, but got %v error", testCase.description, testCase.sendError, err)
+		}
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+	}
+}
+
+func TestSendIf(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	isOpened := func(current fsm.State) bool {
+		return current == opened
+	}
+
+	testCases := []struct {
+		description   string
+		event         fsm.Event
+		pred          func(current fsm.State) bool
+		sendError     error
+		expectedState fsm.State
+	}{
+		{
+			description:   "locking the door only if opened skips the send",
+			event:         evtLock,
+			pred:          isOpened,
+			sendError:     fsm.ErrCondFailed,
+			expectedState: closed,
+		},
+		{
+			description: "opening the door only if closed",
+			event:       evtOpen,
+			pred: func(current fsm.State) bool {
+				return current == closed
+			},
+			sendError:     nil,
+			expectedState: opened,
+		},
+		{
+			description:   "closing the door only if opened",
+			event:         evtClose,
+			pred:          isOpened,
+			sendError:     nil,
+			expectedState: closed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err = door.SendIf(testCase.event, testCase.pred)
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %v, but got %v error", testCase.description, testCase.sendError, err)
+		}
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+	}
+
+	if door.EventCount(evtLock) != 0 {
+		t.Errorf("expected the skipped event not to be counted, but got %d", door.EventCount(evtLock))
+	}
+}
+
+func TestSelfTarget(t *testing.T) {
+	const evtTouch = fsm.Event("touch")
+
+	entries := 0
+	armed := 0
+
+	conf := doorConfig()
+	conf.OnTimeoutArmed = func(state fsm.State, d time.Duration) {
+		armed++
+	}
+	conf.States[0].Entry = func() {
+		entries++
+	}
+	conf.States[0].On = append(conf.States[0].On, fsm.On{
+		{
+			Event: evtTouch,
+			Targets: fsm.Targets{
+				{
+					Target: fsm.Self,
+				},
+			},
+		},
+	}...)
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	err = door.Send(evtTouch)
+	if err != nil {
+		t.Errorf("failed to touch the door: %s", err)
+	}
+
+	if door.State() != closed {
+		t.Errorf("expected %d state but got %d", closed, door.State())
+	}
+
+	if entries != 2 || armed != 2 {
+		t.Errorf("expected the closed door to be re-entered and its timeout re-armed, but got %d entries and %d arms", entries, armed)
+	}
+
+	if !strings.Contains(door.ToPlantUML(), "Closed --> Closed : touch\n") {
+		t.Errorf("expected the self transition to be exported, but got:\n%s", door.ToPlantUML())
+	}
+}
@@ -0,0 +1,134 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+// fuzzReader hands out bytes of the fuzzer's input, once the input
+// is consumed, it keeps returning zero
+type fuzzReader struct {
+	data []byte
+}
+
+func (r *fuzzReader) next(n int) int {
+	if len(r.data) == 0 {
+		return 0
+	}
+
+	b := r.data[0]
+	r.data = r.data[1:]
+
+	return int(b) % n
+}
+
+func (r *fuzzReader) targets() fsm.Targets {
+	targets := make(fsm.Targets, r.next(3))
+	for i := range targets {
+		targets[i].Target = fsm.State(r.next(8))
+		if r.next(2) == 1 {
+			pass := r.next(2) == 1
+			targets[i].Cond = func() bool {
+				return pass
+			}
+		}
+	}
+
+	return targets
+}
+
+func (r *fuzzReader) timeout() *fsm.Timeout {
+	if r.next(3) == 0 {
+		return nil
+	}
+
+	durations := []time.Duration{0, time.Hour}
+	duration := durations[r.next(len(durations))]
+
+	return &fsm.Timeout{
+		Duration:  duration,
+		Immediate: duration == 0,
+		Targets:   r.targets(),
+		OnNoMatch: fsm.NoMatch(r.next(3)),
+	}
+}
+
+func (r *fuzzReader) config() fsm.Config {
+	events := []fsm.Event{"a", "b", "c"}
+
+	conf := fsm.Config{
+		Initial: fsm.State(r.next(8)),
+		States:  make(fsm.States, r.next(6)),
+	}
+
+	for i := range conf.States {
+		conf.States[i].Ref = fsm.State(r.next(8))
+		conf.States[i].Timeout = r.timeout()
+		conf.States[i].On = make(fsm.On, r.next(4))
+		for j := range conf.States[i].On {
+			conf.States[i].On[j].Event = events[r.next(len(events))]
+			conf.States[i].On[j].Targets = r.targets()
+		}
+	}
+
+	return conf
+}
+
+func FuzzNewMachine(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{1, 2, 1, 0, 1, 1, 0, 2, 0, 1, 0, 0})
+	f.Add([]byte{1, 1, 1, 1, 0, 1, 1, 0, 0})
+	f.Add([]byte{1, 2, 1, 1, 0, 1, 2, 0, 0, 2, 1, 0, 1, 1, 0, 0})
+	f.Add([]byte{3, 3, 1, 2, 1, 1, 5, 1, 1, 0, 2, 1, 1, 0, 3, 0, 0, 2, 1, 7, 0})
+
+	sentinels := []error{
+		fsm.ErrInitialNotSet,
+		fsm.ErrDuplicateState,
+		fsm.ErrStateNotFound,
+		fsm.ErrTransitionLoop,
+		fsm.ErrUnreachableTarget,
+		fsm.ErrInvalidState,
+	}
+
+	isSentinel := func(err error, sentinels ...error) bool {
+		for _, sentinel := range sentinels {
+			if errors.Is(err, sentinel) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := &fuzzReader{data: data}
+		conf := r.config()
+
+		m, err := fsm.NewMachine(conf)
+		if err != nil {
+			if !isSentinel(err, sentinels...) {
+				t.Errorf("unexpected error: %s", err)
+			}
+			return
+		}
+
+		declared := make(map[fsm.State]bool)
+		for _, state := range conf.States {
+			declared[state.Ref] = true
+		}
+
+		for _, evt := range []fsm.Event{"a", "b", "c", "a"} {
+			err = m.Send(evt)
+			if err != nil && !isSentinel(err, fsm.ErrNoop, fsm.ErrCondFailed) {
+				t.Errorf("unexpected error on sending %s: %s", evt, err)
+			}
+
+			if !declared[m.State()] {
+				t.Errorf("machine ended up in undeclared state %d", m.State())
+			}
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package fsm
+
+import "sort"
+
+// InGroup reports whether the current state belongs to the given group
+func (m *Machine) InGroup(name string) bool {
+	m.mu.Lock()
+	defer m.unlock()
+
+	return m.groups[name][m.currentState]
+}
+
+func sortedKeys(on map[string]On) []string {
+	names := make([]string, 0, len(on))
+	for name := range on {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// CurrentTags returns the tags of the current state
+func (m *Machine) CurrentTags() []string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	stateInfo, ok := m.states[m.currentState]
+	if !ok || len(stateInfo.Tags) == 0 {
+		return nil
+	}
+
+	tags := make([]string, len(stateInfo.Tags))
+	copy(tags, stateInfo.Tags)
+
+	return tags
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
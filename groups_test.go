@@ -0,0 +1,231 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestGroups(t *testing.T) {
+	conf := doorConfig()
+	conf.Groups = map[string][]fsm.State{
+		"shut": {opened, closed},
+	}
+	conf.GroupOn = map[string]fsm.On{
+		"shut": {
+			{
+				Event: evtLock,
+				Targets: fsm.Targets{
+					{
+						Target: locked,
+					},
+				},
+			},
+		},
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description   string
+		event         fsm.Event
+		expectedState fsm.State
+		inGroup       bool
+	}{
+		{
+			description:   "opening the closed door",
+			event:         evtOpen,
+			expectedState: opened,
+			inGroup:       true,
+		},
+		{
+			description:   "locking the opened door through the group",
+			event:         evtLock,
+			expectedState: locked,
+			inGroup:       false,
+		},
+		{
+			description:   "unlocking the locked door",
+			event:         evtUnlock,
+			expectedState: unlocked,
+			inGroup:       false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err = door.Send(testCase.event)
+		if err != nil {
+			t.Errorf("in %s, unexpected error: %s", testCase.description, err)
+		}
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+
+		if door.InGroup("shut") != testCase.inGroup {
+			t.Errorf("in %s, expected InGroup to be %t", testCase.description, testCase.inGroup)
+		}
+	}
+
+	conf.GroupOn["unknown"] = fsm.On{}
+	_, err = fsm.NewMachine(conf)
+	if !errors.Is(err, fsm.ErrGroupNotFound) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrGroupNotFound, err)
+	}
+}
+
+func TestGlobalOn(t *testing.T) {
+	const evtReset = fsm.Event("reset")
+
+	reset := func(target fsm.State) fsm.On {
+		return fsm.On{
+			{
+				Event: evtReset,
+				Targets: fsm.Targets{
+					{
+						Target: target,
+					},
+				},
+			},
+		}
+	}
+
+	conf := doorConfig()
+	conf.GlobalOn = reset(closed)
+	// a locked door can only be reset to unlocked
+	conf.States[1].On = append(conf.States[1].On, reset(unlocked)...)
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description   string
+		event         fsm.Event
+		expectedState fsm.State
+	}{
+		{
+			description:   "opening the closed door",
+			event:         evtOpen,
+			expectedState: opened,
+		},
+		{
+			description:   "resetting the opened door",
+			event:         evtReset,
+			expectedState: closed,
+		},
+		{
+			description:   "locking the closed door",
+			event:         evtLock,
+			expectedState: locked,
+		},
+		{
+			description:   "resetting the locked door through its own transition",
+			event:         evtReset,
+			expectedState: unlocked,
+		},
+		{
+			description:   "resetting the unlocked door",
+			event:         evtReset,
+			expectedState: closed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err = door.Send(testCase.event)
+		if err != nil {
+			t.Errorf("in %s, unexpected error: %s", testCase.description, err)
+		}
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+	}
+}
+
+func TestTagOn(t *testing.T) {
+	const evtCancel = fsm.Event("cancel")
+
+	conf := doorConfig()
+	conf.States[2].Tags = []string{"interruptible"}
+	conf.States[3].Tags = []string{"interruptible"}
+	conf.TagOn = map[string]fsm.On{
+		"interruptible": {
+			{
+				Event: evtCancel,
+				Targets: fsm.Targets{
+					{
+						Target: closed,
+					},
+				},
+			},
+		},
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	err = door.Send(evtCancel)
+	if !errors.Is(err, fsm.ErrNoop) {
+		t.Errorf("expected %s error for the untagged closed door, but got %v", fsm.ErrNoop, err)
+	}
+
+	testCases := []struct {
+		description   string
+		events        []fsm.Event
+		expectedState fsm.State
+	}{
+		{
+			description:   "canceling the opened door",
+			events:        []fsm.Event{evtOpen},
+			expectedState: opened,
+		},
+		{
+			description:   "canceling the unlocked door",
+			events:        []fsm.Event{evtLock, evtUnlock},
+			expectedState: unlocked,
+		},
+	}
+
+	for _, testCase := range testCases {
+		for _, evt := range testCase.events {
+			err = door.Send(evt)
+			if err != nil {
+				t.Errorf("in %s, unexpected error: %s", testCase.description, err)
+			}
+		}
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+
+		tags := door.CurrentTags()
+		if len(tags) != 1 || tags[0] != "interruptible" {
+			t.Errorf("in %s, expected interruptible tag, but got %v", testCase.description, tags)
+		}
+
+		err = door.Send(evtCancel)
+		if err != nil {
+			t.Errorf("in %s, unexpected error: %s", testCase.description, err)
+		}
+
+		if door.State() != closed {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, closed, door.State())
+		}
+
+		if tags := door.CurrentTags(); len(tags) != 0 {
+			t.Errorf("in %s, expected no tags for the closed door, but got %v", testCase.description, tags)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GuardRegistry maps names to guards, so serialized configs can refer to
+// guards by name. Loaders resolve every name at load time and fail with the
+// full list of unknown names, instead of failing on the first Send
+type GuardRegistry map[string]func() bool
+
+// NewGuardRegistry creates an empty registry
+func NewGuardRegistry() GuardRegistry {
+	return make(GuardRegistry)
+}
+
+// Register adds the guard under the given name, replacing any guard with the same name
+func (r GuardRegistry) Register(name string, fn func() bool) {
+	r[name] = fn
+}
+
+// MustGet returns the guard registered under the given name, it panics if there is none
+func (r GuardRegistry) MustGet(name string) func() bool {
+	fn, ok := r[name]
+	if !ok {
+		panic(fmt.Sprintf("fsm: guard %q is not registered", name))
+	}
+
+	return fn
+}
+
+// Resolve returns the guards registered under the given names, if any of them is
+// not registered, the returned error wraps ErrGuardNotFound and lists all of them
+func (r GuardRegistry) Resolve(names ...string) (map[string]func() bool, error) {
+	guards := make(map[string]func() bool, len(names))
+	missing := make(map[string]bool)
+
+	for _, name := range names {
+		fn, ok := r[name]
+		if !ok {
+			missing[name] = true
+			continue
+		}
+		guards[name] = fn
+	}
+
+	if len(missing) > 0 {
+		list := make([]string, 0, len(missing))
+		for name := range missing {
+			list = append(list, fmt.Sprintf("%q", name))
+		}
+		sort.Strings(list)
+
+		return nil, fmt.Errorf("unresolved guards %s: %w", strings.Join(list, ", "), ErrGuardNotFound)
+	}
+
+	return guards, nil
+}
+
+// InState returns a guard which passes while the other machine is in the given
+// state, so a transition can be gated on another machine. The other machine's
+// state is read under its lock, so the guard must not refer to the machine it
+// guards, and two machines must not guard on each other, as their transitions
+// could wait on each other's lock
+func InState(other *Machine, s State) func() bool {
+	return func() bool {
+		return other.State() == s
+	}
+}
+
+// NotInState returns a guard which passes while the other machine is not in the
+// given state, the same restrictions as for InState apply
+func NotInState(other *Machine, s State) func() bool {
+	return func() bool {
+		return other.State() != s
+	}
+}
@@ -0,0 +1,154 @@
+package fsm_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestGuardRegistry(t *testing.T) {
+	guards := fsm.NewGuardRegistry()
+	guards.Register("isOwner", func() bool { return true })
+
+	resolved, err := guards.Resolve("isOwner", "isOwner")
+	if err != nil {
+		t.Errorf("failed to resolve registered guard: %s", err)
+		return
+	}
+
+	if !resolved["isOwner"]() {
+		t.Errorf("expected registered guard to be resolved")
+	}
+
+	_, err = guards.Resolve("isOwner", "hasKey", "isDaytime")
+	if !errors.Is(err, fsm.ErrGuardNotFound) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrGuardNotFound, err)
+		return
+	}
+
+	for _, name := range []string{`"hasKey"`, `"isDaytime"`} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected error to list %s, but got %s", name, err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected MustGet to panic for unregistered guard")
+		}
+	}()
+
+	guards.MustGet("hasKey")
+}
+
+func TestInState(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		idle
+		armed
+	)
+
+	const (
+		evtArm    = fsm.Event("arm")
+		evtDisarm = fsm.Event("disarm")
+	)
+
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	alarm, err := fsm.NewMachine(fsm.Config{
+		Initial: idle,
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event: evtArm,
+						Cond:  fsm.InState(door, locked),
+						Targets: fsm.Targets{
+							{
+								Target: armed,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: armed,
+				On: fsm.On{
+					{
+						Event: evtDisarm,
+						Cond:  fsm.NotInState(door, locked),
+						Targets: fsm.Targets{
+							{
+								Target: idle,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to create alarm fsm: %s", err)
+		return
+	}
+	defer alarm.Stop()
+
+	testCases := []struct {
+		description   string
+		doorEvent     fsm.Event
+		alarmEvent    fsm.Event
+		expectedErr   error
+		expectedState fsm.State
+	}{
+		{
+			description:   "arming the alarm while the door is closed",
+			alarmEvent:    evtArm,
+			expectedErr:   fsm.ErrCondFailed,
+			expectedState: idle,
+		},
+		{
+			description:   "arming the alarm once the door is locked",
+			doorEvent:     evtLock,
+			alarmEvent:    evtArm,
+			expectedState: armed,
+		},
+		{
+			description:   "disarming the alarm while the door is locked",
+			alarmEvent:    evtDisarm,
+			expectedErr:   fsm.ErrCondFailed,
+			expectedState: armed,
+		},
+		{
+			description:   "disarming the alarm once the door is unlocked",
+			doorEvent:     evtUnlock,
+			alarmEvent:    evtDisarm,
+			expectedState: idle,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.doorEvent != "" {
+			err = door.Send(testCase.doorEvent)
+			if err != nil {
+				t.Errorf("in %s, unexpected door error: %s", testCase.description, err)
+			}
+		}
+
+		err = alarm.Send(testCase.alarmEvent)
+		if !errors.Is(err, testCase.expectedErr) {
+			t.Errorf("in %s, expected %v error, but got %v", testCase.description, testCase.expectedErr, err)
+		}
+
+		if alarm.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, alarm.State())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package fsm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"time"
+)
+
+// ConfigHash returns a SHA-256 fingerprint of the machine's transition table,
+// states, events, targets, timeouts, schedules, event aliases, target selection
+// and whether guards and actions are defined, so machines built from the same
+// configuration hash the same across runs.
+// Functions can't be compared, so changing a guard's logic doesn't change the hash
+func (m *Machine) ConfigHash() string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "initial %d selection=%d\n", m.initial, m.selection)
+
+	aliases := make([]string, 0, len(m.aliases))
+	for alias := range m.aliases {
+		aliases = append(aliases, string(alias))
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		fmt.Fprintf(h, "alias %q %q\n", alias, m.aliases[Event(alias)])
+	}
+
+	refs := make([]State, len(m.order))
+	copy(refs, m.order)
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i] < refs[j]
+	})
+
+	for _, ref := range refs {
+		stateInfo := m.states[ref]
+		fmt.Fprintf(h, "state %d entry=%t entryctx=%t sub=%t done=%q\n", ref, stateInfo.Entry != nil, stateInfo.EntryCtx != nil, stateInfo.SubMachine != nil, stateInfo.OnDone)
+
+		hashTargets(h, "always", stateInfo.Always)
+
+		for _, timeout := range stateInfo.Timeouts {
+			fmt.Fprintf(h, "timeout %s jitter=%s immediate=%t revert=%t cond=%t action=%t fallback=%t nomatch=%d\n",
+				timeout.Duration, timeout.Jitter, timeout.Immediate, timeout.RevertOnTimeout,
+				timeout.Cond != nil, timeout.Action != nil, timeout.Fallback != nil, timeout.OnNoMatch)
+			for _, choice := range timeout.DurationChoices {
+				fmt.Fprintf(h, "choice %s weight=%d\n", choice.Duration, choice.Weight)
+			}
+			hashTargets(h, "timeout", timeout.Targets)
+		}
+
+		events := make([]Event, len(stateInfo.Events))
+		copy(events, stateInfo.Events)
+		sort.Slice(events, func(i, j int) bool {
+			return events[i] < events[j]
+		})
+
+		for _, evt := range events {
+			stateEventInfo, ok := m.nextStates[key{ref, evt}]
+			if !ok {
+				continue
+			}
+
+			fmt.Fprintf(h, "on %q cond=%t count=%d pattern=%t\n", evt,
+				guarded(stateEventInfo.Cond, stateEventInfo.CondCtx, stateEventInfo.Cached),
+				stateEventInfo.Count, stateEventInfo.Pattern)
+			hashSchedule(h, stateEventInfo.Schedule)
+			hashTargets(h, "target", stateEventInfo.Targets)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashSchedule writes the window of the schedule, the weekdays are a set
+func hashSchedule(h hash.Hash, schedule *ScheduleSpec) {
+	if schedule == nil {
+		return
+	}
+
+	weekdays := make([]time.Weekday, len(schedule.Weekdays))
+	copy(weekdays, schedule.Weekdays)
+	sort.Slice(weekdays, func(i, j int) bool {
+		return weekdays[i] < weekdays[j]
+	})
+
+	fmt.Fprintf(h, "schedule weekdays=%v start=%s end=%s\n", weekdays, schedule.Start, schedule.End)
+}
+
+// hashTargets writes the targets in their declared order, as it decides which one is taken
+func hashTargets(h hash.Hash, kind string, targets Targets) {
+	for _, target := range targets {
+		fmt.Fprintf(h, "%s %d cond=%t async=%t\n", kind, target.Target, guarded(target.Cond, target.CondCtx, target.Cached), target.Async != nil)
+	}
+}
@@ -0,0 +1,119 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestConfigHash(t *testing.T) {
+	newDoor := func(modify func(conf *fsm.Config)) (*fsm.Machine, error) {
+		conf := doorConfig()
+		if modify != nil {
+			modify(&conf)
+		}
+
+		return fsm.NewMachine(conf)
+	}
+
+	door, err := newDoor(nil)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	hash := door.ConfigHash()
+
+	testCases := []struct {
+		description string
+		modify      func(conf *fsm.Config)
+		equal       bool
+	}{
+		{
+			description: "an identical door",
+			equal:       true,
+		},
+		{
+			description: "a door with states declared in another order",
+			modify: func(conf *fsm.Config) {
+				conf.States[0], conf.States[1] = conf.States[1], conf.States[0]
+			},
+			equal: true,
+		},
+		{
+			description: "a door which locks later",
+			modify: func(conf *fsm.Config) {
+				conf.States[0].Timeout.Duration = 20 * time.Second
+			},
+		},
+		{
+			description: "a door which selects exactly one target",
+			modify: func(conf *fsm.Config) {
+				conf.TargetSelection = fsm.ExactlyOne
+			},
+		},
+		{
+			description: "a door with an event alias",
+			modify: func(conf *fsm.Config) {
+				conf.EventAliases = map[fsm.Event]fsm.Event{"shut": evtClose}
+			},
+		},
+		{
+			description: "a door with a guarded open",
+			modify: func(conf *fsm.Config) {
+				conf.States[0].On[1].Cond = func() bool {
+					return true
+				}
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		other, err := newDoor(testCase.modify)
+		if err != nil {
+			t.Errorf("in %s, failed to create door fsm: %s", testCase.description, err)
+			continue
+		}
+		defer other.Stop()
+
+		if equal := other.ConfigHash() == hash; equal != testCase.equal {
+			t.Errorf("in %s, expected equal hashes to be %t, but got %t", testCase.description, testCase.equal, equal)
+		}
+	}
+
+	scheduled := func(schedule fsm.ScheduleSpec) string {
+		door, err := newDoor(func(conf *fsm.Config) {
+			conf.States[0].On[1].Schedule = &schedule
+		})
+		if err != nil {
+			t.Errorf("failed to create door fsm: %s", err)
+			return ""
+		}
+		defer door.Stop()
+
+		return door.ConfigHash()
+	}
+
+	weekdays := []time.Weekday{time.Monday, time.Friday}
+	office := scheduled(fsm.ScheduleSpec{Weekdays: weekdays, Start: 9 * time.Hour, End: 17 * time.Hour})
+
+	if scheduled(fsm.ScheduleSpec{Weekdays: []time.Weekday{time.Friday, time.Monday}, Start: 9 * time.Hour, End: 17 * time.Hour}) != office {
+		t.Errorf("expected the order of the weekdays not to change the hash")
+	}
+
+	for _, schedule := range []fsm.ScheduleSpec{
+		{Weekdays: weekdays[:1], Start: 9 * time.Hour, End: 17 * time.Hour},
+		{Weekdays: weekdays, Start: 8 * time.Hour, End: 17 * time.Hour},
+		{Weekdays: weekdays, Start: 9 * time.Hour, End: 18 * time.Hour},
+	} {
+		if scheduled(schedule) == office {
+			t.Errorf("expected the window %+v to change the hash", schedule)
+		}
+	}
+
+	if len(hash) != 64 {
+		t.Errorf("expected a hex encoded SHA-256 hash, but got %q", hash)
+	}
+}
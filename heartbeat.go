@@ -0,0 +1,100 @@
+package fsm
+
+import (
+	"fmt"
+	"time"
+)
+
+// heartbeat watches for an event which must be received within window
+type heartbeat struct {
+	window time.Duration
+	onMiss State
+	armed  uint64
+	cancel func()
+}
+
+// Heartbeat turns the event into a dead man's switch, unless the event is sent
+// again within window, the machine is forced into onMiss like a timeout does,
+// whatever state it is in. Unlike a state's timeouts, the window doesn't depend
+// on the state the machine is in, it only starts over whenever the event is sent,
+// whether the event causes a transition or not. Once missed, the window is armed
+// again by the next heartbeat. While the machine is paused, a missed heartbeat is
+// given another window. Calling Heartbeat again for the event replaces its window and onMiss.
+// ErrInvalidTimeout is returned if window isn't positive, ErrStateNotFound if
+// onMiss is unknown and ErrStopped once the machine is stopped
+func (m *Machine) Heartbeat(evt Event, window time.Duration, onMiss State) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.stopped {
+		return ErrStopped
+	}
+
+	if window <= 0 {
+		return fmt.Errorf("heartbeat %s with %s window: %w", evt, window, ErrInvalidTimeout)
+	}
+
+	if _, ok := m.states[onMiss]; !ok {
+		return fmt.Errorf("heartbeat %s misses to state ref %d: %w", evt, onMiss, ErrStateNotFound)
+	}
+
+	if canonical, ok := m.aliases[evt]; ok {
+		evt = canonical
+	}
+
+	if hb, ok := m.heartbeats[evt]; ok && hb.cancel != nil {
+		hb.cancel()
+	}
+
+	if m.heartbeats == nil {
+		m.heartbeats = make(map[Event]*heartbeat)
+	}
+	m.heartbeats[evt] = &heartbeat{window: window, onMiss: onMiss}
+	m.armHeartbeat(evt)
+
+	return nil
+}
+
+// armHeartbeat starts the window of the event's heartbeat over, if it has one
+func (m *Machine) armHeartbeat(evt Event) {
+	hb, ok := m.heartbeats[evt]
+	if !ok {
+		return
+	}
+
+	if hb.cancel != nil {
+		hb.cancel()
+	}
+
+	// the window may have elapsed while a heartbeat was holding the lock,
+	// so the elapsed window is ignored unless it is still the armed one
+	hb.armed++
+	armed := hb.armed
+
+	hb.cancel = setTimeout(m.clock, func() {
+		m.mu.Lock()
+		defer m.unlock()
+
+		if m.stopped || m.heartbeats[evt] != hb || hb.armed != armed {
+			return
+		}
+		hb.cancel = nil
+
+		if m.isPaused {
+			m.armHeartbeat(evt)
+			return
+		}
+
+		m.force(hb.onMiss)
+	}, hb.window)
+}
+
+// stopHeartbeats cancels the windows of all the heartbeats
+func (m *Machine) stopHeartbeats() {
+	for _, hb := range m.heartbeats {
+		if hb.cancel != nil {
+			hb.cancel()
+			hb.cancel = nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestHeartbeat(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		alive
+		dead
+	)
+
+	const evtPing = fsm.Event("ping")
+
+	monitor, err := fsm.NewMachine(fsm.Config{
+		Initial: alive,
+		States: fsm.States{
+			{
+				Ref: alive,
+			},
+			{
+				Ref: dead,
+			},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to create monitor fsm: %s", err)
+		return
+	}
+	defer monitor.Stop()
+
+	err = monitor.Heartbeat(evtPing, 0, dead)
+	if !errors.Is(err, fsm.ErrInvalidTimeout) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrInvalidTimeout, err)
+	}
+
+	err = monitor.Heartbeat(evtPing, 50*time.Millisecond, 42)
+	if !errors.Is(err, fsm.ErrStateNotFound) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrStateNotFound, err)
+	}
+
+	err = monitor.Heartbeat(evtPing, 50*time.Millisecond, dead)
+	if err != nil {
+		t.Errorf("failed to set the heartbeat: %s", err)
+		return
+	}
+
+	// the pings keep the monitor alive for well over the window
+	for i := 0; i < 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+		monitor.Send(evtPing)
+	}
+
+	if monitor.State() != alive {
+		t.Errorf("expected the monitor to stay alive while pinged, but got %d state", monitor.State())
+		return
+	}
+
+	ok := waitFor(time.Second, func() bool {
+		return monitor.State() == dead
+	})
+	if !ok {
+		t.Errorf("expected the monitor to die once the pings stopped, but got %d state", monitor.State())
+	}
+	// the heartbeat can be set again once it was missed
+	err = monitor.Heartbeat(evtPing, 50*time.Millisecond, alive)
+	if err != nil {
+		t.Errorf("failed to set the heartbeat again: %s", err)
+		return
+	}
+
+	ok = waitFor(time.Second, func() bool {
+		return monitor.State() == alive
+	})
+	if !ok {
+		t.Errorf("expected the monitor to miss the new heartbeat, but got %d state", monitor.State())
+	}
+}
@@ -0,0 +1,212 @@
+package fsm_test
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+const (
+	_ fsm.State = iota
+	unlocked
+	closed
+	opened
+	locked
+)
+
+const (
+	evtOpen   = fsm.Event("open")
+	evtClose  = fsm.Event("close")
+	evtLock   = fsm.Event("lock")
+	evtUnlock = fsm.Event("unlock")
+)
+
+// doorConfig returns the configuration of the door used in TestExampleDoor,
+// a closed door locks itself automatically after 10 seconds
+func doorConfig() fsm.Config {
+	return fsm.Config{
+		Initial: closed,
+		Names: map[fsm.State]string{
+			unlocked: "Unlocked",
+			closed:   "Closed",
+			opened:   "Opened",
+			locked:   "Locked",
+		},
+		States: fsm.States{
+			{
+				Ref: closed,
+				Timeout: &fsm.Timeout{
+					Duration: 10 * time.Second,
+					Targets: fsm.Targets{
+						{
+							Target: locked,
+						},
+					},
+				},
+				On: fsm.On{
+					{
+						Event: evtLock,
+						Targets: fsm.Targets{
+							{
+								Target: locked,
+							},
+						},
+					},
+					{
+						Event: evtOpen,
+						Targets: fsm.Targets{
+							{
+								Target: opened,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: locked,
+				On: fsm.On{
+					{
+						Event: evtUnlock,
+						Targets: fsm.Targets{
+							{
+								Target: unlocked,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: unlocked,
+				On: fsm.On{
+					{
+						Event: evtOpen,
+						Targets: fsm.Targets{
+							{
+								Target: opened,
+							},
+						},
+					},
+					{
+						Event: evtLock,
+						Targets: fsm.Targets{
+							{
+								Target: locked,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: opened,
+				On: fsm.On{
+					{
+						Event: evtClose,
+						Targets: fsm.Targets{
+							{
+								Target: closed,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// waitFor polls cond until it returns true or the timeout is passed
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return cond()
+}
+
+const (
+	_ fsm.State = iota + 100
+	red
+	yellow
+	green
+)
+
+const (
+	evtToggle = fsm.Event("toggle")
+)
+
+// trafficLightConfig returns the configuration of the traffic light used in
+// TestTrafficLightMachine with the given duration for every light
+func trafficLightConfig(duration time.Duration) fsm.Config {
+	lights := []struct {
+		ref  fsm.State
+		next fsm.State
+	}{
+		{red, green},
+		{yellow, red},
+		{green, yellow},
+	}
+
+	states := make(fsm.States, len(lights))
+	for i, light := range lights {
+		states[i].Ref = light.ref
+		states[i].Timeout = &fsm.Timeout{
+			Duration: duration,
+			Targets: fsm.Targets{
+				{
+					Target: light.next,
+				},
+			},
+		}
+		states[i].On = fsm.On{
+			{
+				Event: evtToggle,
+				Targets: fsm.Targets{
+					{
+						Target: light.next,
+					},
+				},
+			},
+		}
+	}
+
+	return fsm.Config{
+		Initial: red,
+		Names: map[fsm.State]string{
+			red:    "red",
+			yellow: "yellow",
+			green:  "green",
+		},
+		States: states,
+	}
+}
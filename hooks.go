@@ -0,0 +1,125 @@
+package fsm
+
+type edge struct {
+	From State
+	To   State
+}
+
+type edgeHook struct {
+	id uint64
+	fn func()
+}
+
+type errorHandler struct {
+	id uint64
+	fn func(error)
+}
+
+// OnTransitionBetween registers fn to be called whenever the machine moves from
+// the given state to the given state, it returns a function which removes the hook
+func (m *Machine) OnTransitionBetween(from, to State, fn func()) (unsubscribe func()) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.edgeHooks == nil {
+		m.edgeHooks = make(map[edge][]edgeHook)
+	}
+
+	m.hookID++
+	id := m.hookID
+	e := edge{from, to}
+	m.edgeHooks[e] = append(m.edgeHooks[e], edgeHook{id: id, fn: fn})
+
+	return func() {
+		m.mu.Lock()
+		defer m.unlock()
+
+		hooks := m.edgeHooks[e]
+		for i, hook := range hooks {
+			if hook.id != id {
+				continue
+			}
+
+			m.edgeHooks[e] = append(hooks[:i:i], hooks[i+1:]...)
+			break
+		}
+
+		if len(m.edgeHooks[e]) == 0 {
+			delete(m.edgeHooks, e)
+		}
+	}
+}
+
+// SetStateChanged replaces the StateChanged callback given to NewMachine,
+// passing nil disables it. A transition in progress keeps using the old one
+func (m *Machine) SetStateChanged(fn func(prev State, next State)) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.stateChanged = fn
+}
+
+// batch holds StateChanged back until the returned function is called, which
+// calls it once for all the hops taken in between, if CoalesceCallbacks is set
+func (m *Machine) batch() func() {
+	if !m.coalesceCalls || m.batching {
+		return func() {}
+	}
+
+	m.batching = true
+	m.batchMoved = false
+	m.batchPrev = m.currentState
+
+	return func() {
+		m.batching = false
+		if !m.batchMoved {
+			return
+		}
+
+		if stateChanged := m.stateChanged; stateChanged != nil {
+			prev, next := m.batchPrev, m.currentState
+			m.callback(func() {
+				stateChanged(prev, next)
+			})
+		}
+	}
+}
+
+func (m *Machine) runEdgeHooks(from, to State) {
+	for _, hook := range m.edgeHooks[edge{from, to}] {
+		m.callback(hook.fn)
+	}
+}
+
+// OnError registers fn to be called with every error which happens inside the
+// machine without a caller to return it to, such as a failing timeout transition,
+// as well as guard panics. It returns a function which removes the handler
+func (m *Machine) OnError(fn func(error)) (unsubscribe func()) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.hookID++
+	id := m.hookID
+	m.errHandlers = append(m.errHandlers, errorHandler{id: id, fn: fn})
+
+	return func() {
+		m.mu.Lock()
+		defer m.unlock()
+
+		for i, handler := range m.errHandlers {
+			if handler.id == id {
+				m.errHandlers = append(m.errHandlers[:i:i], m.errHandlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (m *Machine) reportError(err error) {
+	for _, handler := range m.errHandlers {
+		fn := handler.fn
+		m.callLater(func() {
+			fn(err)
+		})
+	}
+}
@@ -0,0 +1,647 @@
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestOnTransitionBetween(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	count := 0
+	unsubscribe := door.OnTransitionBetween(closed, locked, func() {
+		count++
+	})
+
+	testCases := []struct {
+		description   string
+		event         fsm.Event
+		expectedCount int
+	}{
+		{
+			description:   "locking the closed door",
+			event:         evtLock,
+			expectedCount: 1,
+		},
+		{
+			description:   "unlocking the locked door",
+			event:         evtUnlock,
+			expectedCount: 1,
+		},
+		{
+			description:   "locking the unlocked door",
+			event:         evtLock,
+			expectedCount: 1,
+		},
+		{
+			description:   "unlocking the locked door again",
+			event:         evtUnlock,
+			expectedCount: 1,
+		},
+		{
+			description:   "opening the unlocked door",
+			event:         evtOpen,
+			expectedCount: 1,
+		},
+		{
+			description:   "closing the opened door",
+			event:         evtClose,
+			expectedCount: 1,
+		},
+		{
+			description:   "locking the closed door again",
+			event:         evtLock,
+			expectedCount: 2,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err = door.Send(testCase.event)
+		if err != nil {
+			t.Errorf("in %s, unexpected error: %s", testCase.description, err)
+		}
+
+		if count != testCase.expectedCount {
+			t.Errorf("in %s, expected hook to be called %d times, but got %d", testCase.description, testCase.expectedCount, count)
+		}
+	}
+
+	unsubscribe()
+
+	door.Send(evtUnlock)
+	door.Send(evtOpen)
+	door.Send(evtClose)
+	door.Send(evtLock)
+
+	if count != 2 {
+		t.Errorf("expected hook not to be called after unsubscribe, but got %d calls", count)
+	}
+}
+
+func TestOnError(t *testing.T) {
+	conf := doorConfig()
+	conf.States[0].Timeout.Duration = 10 * time.Millisecond
+	conf.States[0].Timeout.OnNoMatch = fsm.Stay
+	conf.States[0].Timeout.Targets[0].Cond = func() bool {
+		panic("sensor is broken")
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	var mu sync.Mutex
+	var first, second []error
+
+	door.OnError(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		first = append(first, err)
+	})
+	door.OnError(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		second = append(second, err)
+	})
+
+	ok := waitFor(time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(first) > 0 && len(second) > 0
+	})
+
+	if !ok {
+		t.Errorf("expected both handlers to be called")
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, errs := range [][]error{first, second} {
+		if len(errs) != 1 || !errors.Is(errs[0], fsm.ErrGuardPanic) {
+			t.Errorf("expected a single %s error, but got %v", fsm.ErrGuardPanic, errs)
+		}
+	}
+
+	if door.State() != closed {
+		t.Errorf("expected %d state but got %d", closed, door.State())
+	}
+}
+
+func TestSetStateChanged(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	door.Send(evtOpen)
+
+	var transitions [][2]fsm.State
+	door.SetStateChanged(func(prev, next fsm.State) {
+		transitions = append(transitions, [2]fsm.State{prev, next})
+	})
+
+	door.Send(evtClose)
+
+	if len(transitions) != 1 || transitions[0] != [2]fsm.State{opened, closed} {
+		t.Errorf("expected only the opened to closed transition, but got %v", transitions)
+	}
+
+	door.SetStateChanged(nil)
+	door.Send(evtOpen)
+
+	if len(transitions) != 1 {
+		t.Errorf("expected the disabled callback not to be called, but got %v", transitions)
+	}
+}
+
+func TestOnGuardError(t *testing.T) {
+	const broken = locked + 1
+
+	conf := doorConfig()
+	conf.States = append(conf.States, conf.States[3])
+	conf.States[len(conf.States)-1].Ref = broken
+	conf.States[len(conf.States)-1].On = nil
+	conf.States[0].On[1].Cond = func() bool {
+		panic("sensor failure")
+	}
+
+	var guardErr error
+	conf.OnGuardError = func(err error) (fsm.State, bool) {
+		guardErr = err
+		return broken, true
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	err = door.Send(evtOpen)
+	if err != nil {
+		t.Errorf("expected the guard error to be handled, but got %s", err)
+	}
+
+	if !errors.Is(guardErr, fsm.ErrGuardPanic) {
+		t.Errorf("expected %s error to be handed to OnGuardError, but got %v", fsm.ErrGuardPanic, guardErr)
+	}
+
+	if door.State() != broken {
+		t.Errorf("expected %d error state but got %d", broken, door.State())
+	}
+}
+
+func TestAsyncCallbacks(t *testing.T) {
+	var mu sync.Mutex
+	var transitions [][2]fsm.State
+
+	conf := doorConfig()
+	conf.AsyncCallbacks = true
+	conf.StateChanged = func(prev, next fsm.State) {
+		time.Sleep(100 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		transitions = append(transitions, [2]fsm.State{prev, next})
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	start := time.Now()
+	door.Send(evtOpen)
+	door.Send(evtClose)
+
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected Send not to wait for the slow callback, but it took %s", elapsed)
+	}
+
+	ok := waitFor(time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(transitions) == 2
+	})
+	if !ok {
+		t.Errorf("expected both callbacks to run")
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := [][2]fsm.State{{closed, opened}, {opened, closed}}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("expected callbacks in order %v, but got %v", expected, transitions)
+			break
+		}
+	}
+}
+
+func TestReentrantSend(t *testing.T) {
+	var door *fsm.Machine
+	var states []fsm.State
+
+	conf := doorConfig()
+	conf.StateChanged = func(prev, next fsm.State) {
+		states = append(states, next)
+
+		// the door locks itself once closed
+		if next == closed {
+			err := door.Send(evtLock)
+			if err != nil {
+				t.Errorf("expected the re-entrant send to lock the door, but got %s", err)
+			}
+		}
+	}
+
+	var err error
+	door, err = fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for _, evt := range []fsm.Event{evtOpen, evtClose} {
+			err := door.Send(evt)
+			if err != nil {
+				t.Errorf("failed to send %s: %s", evt, err)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("expected the re-entrant send not to deadlock")
+		return
+	}
+
+	expected := []fsm.State{opened, closed, locked}
+	if len(states) != len(expected) {
+		t.Errorf("expected %v states, but got %v", expected, states)
+		return
+	}
+
+	for i := range expected {
+		if states[i] != expected[i] {
+			t.Errorf("expected %v states, but got %v", expected, states)
+			break
+		}
+	}
+}
+
+func TestCoalesceCallbacks(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		idle
+		validating
+		saving
+		done
+	)
+
+	const evtSubmit = fsm.Event("submit")
+
+	testCases := []struct {
+		description string
+		coalesce    bool
+		expected    [][2]fsm.State
+	}{
+		{
+			description: "submitting a form",
+			coalesce:    false,
+			expected:    [][2]fsm.State{{idle, validating}, {validating, saving}, {saving, done}},
+		},
+		{
+			description: "submitting a form with coalesced callbacks",
+			coalesce:    true,
+			expected:    [][2]fsm.State{{idle, done}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		var transitions [][2]fsm.State
+
+		form, err := fsm.NewMachine(fsm.Config{
+			Initial: idle,
+			StateChanged: func(prev, next fsm.State) {
+				transitions = append(transitions, [2]fsm.State{prev, next})
+			},
+			CoalesceCallbacks: testCase.coalesce,
+			States: fsm.States{
+				{
+					Ref: idle,
+					On: fsm.On{
+						{
+							Event:   evtSubmit,
+							Targets: fsm.Targets{{Target: validating}},
+						},
+					},
+				},
+				{
+					Ref:    validating,
+					Always: fsm.Targets{{Target: saving}},
+				},
+				{
+					Ref:    saving,
+					Always: fsm.Targets{{Target: done}},
+				},
+				{
+					Ref: done,
+				},
+			},
+		})
+		if err != nil {
+			t.Errorf("in %s, failed to create form fsm: %s", testCase.description, err)
+			continue
+		}
+
+		err = form.Send(evtSubmit)
+		if err != nil {
+			t.Errorf("in %s, unexpected error: %s", testCase.description, err)
+		}
+
+		if !reflect.DeepEqual(transitions, testCase.expected) {
+			t.Errorf("in %s, expected %v transitions, but got %v", testCase.description, testCase.expected, transitions)
+		}
+
+		form.Stop()
+	}
+}
+
+func TestReentrantSendEntryPoints(t *testing.T) {
+	testCases := []struct {
+		description string
+		send        func(door *fsm.Machine) error
+	}{
+		{
+			description: "SendContext",
+			send: func(door *fsm.Machine) error {
+				return door.SendContext(context.Background(), evtLock)
+			},
+		},
+		{
+			description: "SendTimed",
+			send: func(door *fsm.Machine) error {
+				_, err := door.SendTimed(evtLock)
+				return err
+			},
+		},
+		{
+			description: "SendIf",
+			send: func(door *fsm.Machine) error {
+				return door.SendIf(evtLock, func(current fsm.State) bool {
+					return current == closed
+				})
+			},
+		},
+		{
+			description: "SendEx",
+			send: func(door *fsm.Machine) error {
+				_, err := door.SendEx(evtLock)
+				return err
+			},
+		},
+		{
+			description: "TrySequence",
+			send: func(door *fsm.Machine) error {
+				return door.TrySequence(evtLock)
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		var door *fsm.Machine
+
+		conf := doorConfig()
+		conf.StateChanged = func(prev, next fsm.State) {
+			if next == closed {
+				err := testCase.send(door)
+				if err != nil {
+					t.Errorf("in %s, expected the re-entrant send to lock the door, but got %s", testCase.description, err)
+				}
+			}
+		}
+
+		var err error
+		door, err = fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("in %s, failed to create door fsm: %s", testCase.description, err)
+			continue
+		}
+
+		sendWithin(t, testCase.description, door, evtOpen, evtClose)
+
+		if door.State() != locked {
+			t.Errorf("in %s, expected the door to lock itself, but got %d state", testCase.description, door.State())
+		}
+
+		door.Stop()
+	}
+}
+
+func TestReentrantSendPending(t *testing.T) {
+	var door *fsm.Machine
+	var pendingErr, commitErr error
+
+	conf := doorConfig()
+	conf.StateChanged = func(prev, next fsm.State) {
+		if next != opened {
+			return
+		}
+
+		var pending *fsm.PendingTransition
+		pending, pendingErr = door.SendPending(evtClose)
+		if pendingErr == nil {
+			commitErr = pending.Commit()
+		}
+	}
+
+	var err error
+	door, err = fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	sendWithin(t, "opening the door", door, evtOpen)
+
+	if pendingErr != nil || commitErr != nil {
+		t.Errorf("expected the pending transition to be committed, but got %v and %v", pendingErr, commitErr)
+	}
+
+	if door.State() != closed {
+		t.Errorf("expected the committed transition to close the door, but got %d state", door.State())
+	}
+}
+
+func TestReentrantStep(t *testing.T) {
+	var door *fsm.Machine
+
+	conf := doorConfig()
+	conf.StepMode = true
+	conf.StateChanged = func(prev, next fsm.State) {
+		if next == opened {
+			door.Step()
+		}
+	}
+
+	var err error
+	door, err = fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	door.Send(evtOpen)
+	door.Send(evtClose)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		door.Step()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("expected the re-entrant step not to deadlock")
+		return
+	}
+
+	if door.State() != closed {
+		t.Errorf("expected both steps to be applied, but got %d state", door.State())
+	}
+}
+
+func TestSendDuringCallback(t *testing.T) {
+	entered := make(chan struct{})
+
+	conf := doorConfig()
+	conf.StateChanged = func(prev, next fsm.State) {
+		if next == opened {
+			close(entered)
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	go door.Send(evtOpen)
+	<-entered
+
+	// a send from another goroutine is not mistaken for a re-entrant one
+	err = door.Send("bogus")
+	if err != fsm.ErrNoop {
+		t.Errorf("expected %s error, but got %v", fsm.ErrNoop, err)
+	}
+
+	result, err := door.SendEx("bogus")
+	if err != fsm.ErrNoop || result.Matched {
+		t.Errorf("expected an unmatched %s result, but got %+v and %v", fsm.ErrNoop, result, err)
+	}
+
+	err = door.Send(evtClose)
+	if err != nil {
+		t.Errorf("failed to send %s: %s", evtClose, err)
+	}
+
+	if door.State() != closed {
+		t.Errorf("expected the door to close, but got %d state", door.State())
+	}
+}
+
+func TestReentrantOnError(t *testing.T) {
+	var door *fsm.Machine
+
+	conf := doorConfig()
+	conf.States[0].On[0].Cond = func() bool {
+		panic("broken lock")
+	}
+	conf.OnError = func(err error) {
+		if errors.Is(err, fsm.ErrGuardPanic) {
+			door.Send(evtOpen)
+		}
+	}
+
+	var err error
+	door, err = fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		err := door.Send(evtLock)
+		if !errors.Is(err, fsm.ErrGuardPanic) {
+			t.Errorf("expected %s error, but got %v", fsm.ErrGuardPanic, err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("expected the error handler's send not to deadlock")
+		return
+	}
+
+	if door.State() != opened {
+		t.Errorf("expected the error handler to open the door, but got %d state", door.State())
+	}
+}
+
+// sendWithin sends the events in order and fails the test if they don't complete within a second
+func sendWithin(t *testing.T, description string, m *fsm.Machine, evts ...fsm.Event) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for _, evt := range evts {
+			err := m.Send(evt)
+			if err != nil {
+				t.Errorf("in %s, failed to send %s: %s", description, evt, err)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("in %s, expected the re-entrant send not to deadlock", description)
+	}
+}
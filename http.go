@@ -0,0 +1,113 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type httpStatus struct {
+	State              string   `json:"state"`
+	Events             []string `json:"events"`
+	TimeoutRemainingMs *int64   `json:"timeout_remaining_ms,omitempty"`
+}
+
+type httpError struct {
+	Error string `json:"error"`
+}
+
+// Handler exposes the machine over HTTP. GET / returns the status of the machine,
+// its current state, allowed events and the remaining time of the next timeout.
+// POST /send?event=X sends the event and returns the new status, ErrStateNotFound
+// is reported as 404, the errors of events which don't change the state, such
+// as ErrNoop, ErrCondFailed and ErrConfirmRequired, as 409 and a paused or
+// stopped machine as 503
+func Handler(m *Machine) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, httpError{Error: "method not allowed"})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, m.httpStatus())
+	})
+
+	mux.HandleFunc("/send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, httpError{Error: "method not allowed"})
+			return
+		}
+
+		evt := r.URL.Query().Get("event")
+		if evt == "" {
+			writeJSON(w, http.StatusBadRequest, httpError{Error: "event is required"})
+			return
+		}
+
+		err := m.Send(Event(evt))
+		if err != nil {
+			writeJSON(w, httpStatusCode(err), httpError{Error: err.Error()})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, m.httpStatus())
+	})
+
+	return mux
+}
+
+func httpStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrStateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNoop),
+		errors.Is(err, ErrUnknownEvent),
+		errors.Is(err, ErrCondFailed),
+		errors.Is(err, ErrOutsideSchedule),
+		errors.Is(err, ErrCounting),
+		errors.Is(err, ErrConfirmRequired),
+		errors.Is(err, ErrAmbiguousTarget):
+		return http.StatusConflict
+	case errors.Is(err, ErrPaused),
+		errors.Is(err, ErrStopped):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (m *Machine) httpStatus() httpStatus {
+	m.mu.Lock()
+	defer m.unlock()
+
+	status := httpStatus{
+		State:  m.stateName(m.currentState),
+		Events: make([]string, 0),
+	}
+
+	for _, evt := range m.allowedEvents() {
+		status.Events = append(status.Events, string(evt))
+	}
+
+	for _, armed := range m.timeouts {
+		remaining := m.remaining(armed).Milliseconds()
+		if status.TimeoutRemainingMs == nil || remaining < *status.TimeoutRemainingMs {
+			status.TimeoutRemainingMs = &remaining
+		}
+	}
+
+	return status
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
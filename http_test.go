@@ -0,0 +1,149 @@
+package fsm_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestHandler(t *testing.T) {
+	conf := doorConfig()
+	conf.Clock = newFakeClock(time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC))
+	conf.RequireConfirm = map[fsm.Event]bool{evtLock: true}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	server := httptest.NewServer(fsm.Handler(door))
+	defer server.Close()
+
+	type status struct {
+		State              string   `json:"state"`
+		Events             []string `json:"events"`
+		TimeoutRemainingMs *int64   `json:"timeout_remaining_ms"`
+		Error              string   `json:"error"`
+	}
+
+	testCases := []struct {
+		description    string
+		prepare        func()
+		method         string
+		path           string
+		expectedCode   int
+		expectedState  string
+		expectedEvents int
+		expectTimeout  bool
+	}{
+		{
+			description:    "getting the status of the closed door",
+			method:         http.MethodGet,
+			path:           "/",
+			expectedCode:   http.StatusOK,
+			expectedState:  "Closed",
+			expectedEvents: 2,
+			expectTimeout:  true,
+		},
+		{
+			description:    "opening the closed door",
+			method:         http.MethodPost,
+			path:           "/send?event=open",
+			expectedCode:   http.StatusOK,
+			expectedState:  "Opened",
+			expectedEvents: 1,
+		},
+		{
+			description:  "opening the opened door",
+			method:       http.MethodPost,
+			path:         "/send?event=open",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			description:  "locking the door without confirmation",
+			method:       http.MethodPost,
+			path:         "/send?event=lock",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			description:  "sending without an event",
+			method:       http.MethodPost,
+			path:         "/send",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			description:  "sending with get",
+			method:       http.MethodGet,
+			path:         "/send?event=close",
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			description:  "closing the paused door",
+			prepare:      door.Pause,
+			method:       http.MethodPost,
+			path:         "/send?event=close",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			description:  "closing the stopped door",
+			prepare:      door.Stop,
+			method:       http.MethodPost,
+			path:         "/send?event=close",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.prepare != nil {
+			testCase.prepare()
+		}
+
+		req, err := http.NewRequest(testCase.method, server.URL+testCase.path, nil)
+		if err != nil {
+			t.Errorf("in %s, failed to create request: %s", testCase.description, err)
+			continue
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("in %s, failed to send request: %s", testCase.description, err)
+			continue
+		}
+
+		var body status
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			t.Errorf("in %s, failed to decode response: %s", testCase.description, err)
+			continue
+		}
+
+		if resp.StatusCode != testCase.expectedCode {
+			t.Errorf("in %s, expected %d status code, but got %d", testCase.description, testCase.expectedCode, resp.StatusCode)
+		}
+
+		if testCase.expectedCode != http.StatusOK {
+			if body.Error == "" {
+				t.Errorf("in %s, expected an error message", testCase.description)
+			}
+			continue
+		}
+
+		if body.State != testCase.expectedState || len(body.Events) != testCase.expectedEvents {
+			t.Errorf("in %s, expected %s state with %d events, but got %+v", testCase.description, testCase.expectedState, testCase.expectedEvents, body)
+		}
+
+		if (body.TimeoutRemainingMs != nil) != testCase.expectTimeout {
+			t.Errorf("in %s, expected timeout to be reported: %t", testCase.description, testCase.expectTimeout)
+		}
+
+		if testCase.expectTimeout && *body.TimeoutRemainingMs != 10000 {
+			t.Errorf("in %s, expected 10000ms remaining, but got %d", testCase.description, *body.TimeoutRemainingMs)
+		}
+	}
+}
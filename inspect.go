@@ -0,0 +1,42 @@
+package fsm
+
+// Node is a state of the machine's hierarchy, Children are the states of the
+// state's SubMachine and Active is set along the path of the current states
+type Node struct {
+	State    State
+	Name     string
+	Active   bool
+	Children []*Node
+}
+
+// Inspect returns the hierarchy of the machine's states, the returned node is
+// the machine itself whose Children are its states in the order of declaration,
+// so a flat machine is a single level of nodes
+func (m *Machine) Inspect() *Node {
+	return &Node{
+		Active:   true,
+		Children: m.inspect(true),
+	}
+}
+
+func (m *Machine) inspect(active bool) []*Node {
+	m.mu.Lock()
+	defer m.unlock()
+
+	nodes := make([]*Node, 0, len(m.order))
+	for _, ref := range m.order {
+		node := &Node{
+			State:  ref,
+			Name:   m.stateName(ref),
+			Active: active && ref == m.currentState,
+		}
+
+		if sub := m.states[ref].SubMachine; sub != nil {
+			node.Children = sub.inspect(node.Active)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
@@ -0,0 +1,134 @@
+package fsm_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestInspect(t *testing.T) {
+	const (
+		EvtStart = fsm.Event("start")
+		EvtNext  = fsm.Event("next")
+	)
+
+	const (
+		_ fsm.State = iota
+		idle
+		working
+	)
+
+	const (
+		_ fsm.State = iota
+		drafting
+		reviewing
+	)
+
+	forward := func(evt fsm.Event, target fsm.State) fsm.On {
+		return fsm.On{
+			{
+				Event: evt,
+				Targets: fsm.Targets{
+					{
+						Target: target,
+					},
+				},
+			},
+		}
+	}
+
+	task, err := fsm.NewMachine(fsm.Config{
+		Initial: drafting,
+		Names: map[fsm.State]string{
+			drafting:  "drafting",
+			reviewing: "reviewing",
+		},
+		States: fsm.States{
+			{
+				Ref: drafting,
+				On:  forward(EvtNext, reviewing),
+			},
+			{
+				Ref: reviewing,
+				On:  forward(EvtNext, drafting),
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized sub machine: %s", err)
+		return
+	}
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial: idle,
+		Names: map[fsm.State]string{
+			idle:    "idle",
+			working: "working",
+		},
+		States: fsm.States{
+			{
+				Ref: idle,
+				On:  forward(EvtStart, working),
+			},
+			{
+				Ref:        working,
+				SubMachine: task,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	var activePath func(nodes []*fsm.Node) []string
+	activePath = func(nodes []*fsm.Node) []string {
+		for _, node := range nodes {
+			if node.Active {
+				return append([]string{node.Name}, activePath(node.Children)...)
+			}
+		}
+		return nil
+	}
+
+	testCases := []struct {
+		description  string
+		event        fsm.Event
+		expectedPath []string
+	}{
+		{
+			description:  "idle machine",
+			expectedPath: []string{"idle"},
+		},
+		{
+			description:  "starting the work",
+			event:        EvtStart,
+			expectedPath: []string{"working", "drafting"},
+		},
+		{
+			description:  "moving the sub machine",
+			event:        EvtNext,
+			expectedPath: []string{"working", "reviewing"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.event != "" {
+			m.Send(testCase.event)
+		}
+
+		root := m.Inspect()
+		if len(root.Children) != 2 || len(root.Children[1].Children) != 2 {
+			t.Errorf("in %s, expected two levels of two states, but got %+v", testCase.description, root)
+			continue
+		}
+
+		path := activePath(root.Children)
+		if !reflect.DeepEqual(path, testCase.expectedPath) {
+			t.Errorf("in %s, expected %v active path, but got %v", testCase.description, testCase.expectedPath, path)
+		}
+	}
+}
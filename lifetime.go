@@ -0,0 +1,52 @@
+package fsm
+
+// armLifetime starts the timer which ends the machine's lifetime
+func (m *Machine) armLifetime() {
+	if m.maxLifetime <= 0 {
+		return
+	}
+
+	m.expire = setTimeout(m.clock, m.expireLifetime, m.maxLifetime)
+}
+
+// expireLifetime forces the machine into ExpireTarget, whatever state it is in
+func (m *Machine) expireLifetime() {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.stopped {
+		return
+	}
+	m.expire = nil
+
+	// the pause only holds the timeouts of the state which is left now
+	m.isPaused = false
+	m.paused = nil
+
+	m.force(m.expireTarget)
+}
+
+// force moves the machine into the state, whatever state it is in, like a
+// timeout does, the errors are reported to the OnError handlers
+func (m *Machine) force(state State) {
+	defer m.notify()
+
+	m.guardErr = nil
+	m.actionErr = nil
+
+	m.haltSubMachine()
+	m.changeState(state, true)
+	if err := m.process(state); err != nil {
+		m.reportError(err)
+	}
+
+	m.reportGuardErr()
+
+	if m.actionErr != nil {
+		m.reportError(m.actionErr)
+		m.actionErr = nil
+	}
+
+	// the state of an outstanding transition may have been left
+	m.drainQueued()
+}
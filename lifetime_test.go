@@ -0,0 +1,57 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestMaxLifetime(t *testing.T) {
+	conf := doorConfig()
+	conf.MaxLifetime = 50 * time.Millisecond
+	conf.ExpireTarget = locked
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	// keep the door busy, so its own timeout never fires
+	deadline := time.Now().Add(time.Second)
+	for door.State() != locked && time.Now().Before(deadline) {
+		door.Send(evtOpen)
+		door.Send(evtClose)
+		time.Sleep(time.Millisecond)
+	}
+
+	if door.State() != locked {
+		t.Errorf("expected the door to expire into %d state, but got %d", locked, door.State())
+	}
+
+	err = door.Send(evtOpen)
+	if err != fsm.ErrNoop {
+		t.Errorf("expected %s error opening the expired door, but got %v", fsm.ErrNoop, err)
+	}
+}
+
+func TestMaxLifetimeStop(t *testing.T) {
+	conf := doorConfig()
+	conf.MaxLifetime = 10 * time.Millisecond
+	conf.ExpireTarget = locked
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	door.Stop()
+	time.Sleep(30 * time.Millisecond)
+
+	if door.State() != closed {
+		t.Errorf("expected the stopped door to stay in %d state, but got %d", closed, door.State())
+	}
+}
@@ -1,9 +1,17 @@
 package fsm
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unsafe"
 )
 
 var (
@@ -17,6 +25,49 @@ var (
 	ErrCondFailed = errors.New("condition failed")
 	// ErrStateNotFound happens when an unknown state is being set
 	ErrStateNotFound = errors.New("state not found")
+	// ErrTransitionLoop happens when Always transitions or zero duration timeouts move the machine in a loop forever,
+	// or when a chain of guarded Always transitions goes on for too long
+	ErrTransitionLoop = errors.New("transition loop")
+	// ErrOutsideSchedule happens at Send if the transition's Schedule doesn't allow it at the moment
+	ErrOutsideSchedule = errors.New("outside of schedule")
+	// ErrGuardPanic happens when a guard panics, the guard is considered as failed
+	ErrGuardPanic = errors.New("guard panicked")
+	// ErrGroupNotFound happens when transitions are attached to an unknown group
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrUnreachableTarget happens when a target follows an unconditional target, so it can never be selected
+	ErrUnreachableTarget = errors.New("unreachable target")
+	// ErrMergeConflict happens when MergeConfigs finds the same thing defined in both configs
+	ErrMergeConflict = errors.New("merge conflict")
+	// ErrGuardNotFound happens when a serialized config refers to a guard which is not registered
+	ErrGuardNotFound = errors.New("guard not found")
+	// ErrCounting happens at Send if the transition's Count is not reached yet
+	ErrCounting = errors.New("counting")
+	// ErrActionTimeout happens when an action runs longer than the machine's ActionTimeout
+	ErrActionTimeout = errors.New("action timed out")
+	// ErrNonContiguousStates happens when the declared states don't form the range expected by ExpectContiguousFrom
+	ErrNonContiguousStates = errors.New("states are not contiguous")
+	// ErrStopped happens when the machine is used after Stop
+	ErrStopped = errors.New("machine stopped")
+	// ErrSharedSubMachine happens when a configuration with sub machines is compiled to be shared
+	ErrSharedSubMachine = errors.New("sub machine can't be shared")
+	// ErrInvalidTimeout happens when a timeout's Duration is not positive and it isn't Immediate
+	ErrInvalidTimeout = errors.New("invalid timeout")
+	// ErrPaused happens when an event is sent to a paused machine
+	ErrPaused = errors.New("machine paused")
+	// ErrAmbiguousTarget happens in ExactlyOne target selection when more than one target passes its guards
+	ErrAmbiguousTarget = errors.New("ambiguous target")
+	// ErrUnknownEvent happens in strict mode when the current state has no transition for the sent event
+	ErrUnknownEvent = errors.New("unknown event")
+	// ErrConfirmRequired happens at Send if the event requires confirmation, it must be sent with SendPending
+	ErrConfirmRequired = errors.New("confirmation required")
+	// ErrPendingExpired happens when a pending transition is committed after it was committed or aborted, or the machine left its state
+	ErrPendingExpired = errors.New("pending transition expired")
+	// ErrUnreachableState happens in strict reachability mode when a state can't be reached from the initial state
+	ErrUnreachableState = errors.New("unreachable state")
+	// ErrMissingHandler happens when RequireHandles finds states which don't handle a required event
+	ErrMissingHandler = errors.New("missing handler")
+	// ErrInvalidState happens when a state is declared with ref 0 or Self, which can't refer to a state
+	ErrInvalidState = errors.New("invalid state")
 )
 
 // Event is a custom type which defines machine's events
@@ -25,32 +76,146 @@ type Event string
 // State is a custom type which defines machine's states
 type State uint32
 
+// Self used as a Target resolves to the state the transition is declared on,
+// so a self transition doesn't repeat the state's ref. It must not be used as a Ref
+const Self State = math.MaxUint32
+
+// NoMatch defines what a Timeout does when none of its Targets passes its Cond
+type NoMatch int
+
+const (
+	// ReArm schedules the timeout again, so the targets are checked
+	// once more after another Duration
+	ReArm NoMatch = iota
+	// Stay keeps the machine in the current state with no armed timeout
+	Stay
+	// CallFallback calls the timeout's Fallback and stays in the current state
+	// with no armed timeout
+	CallFallback
+)
+
+// TargetSelection defines how a transition selects its target among its Targets
+type TargetSelection int
+
+const (
+	// FirstMatch selects the first target whose guards pass
+	FirstMatch TargetSelection = iota
+	// ExactlyOne evaluates the guards of all the targets and fails with
+	// ErrAmbiguousTarget if more than one passes, which catches overlapping guards
+	ExactlyOne
+)
+
 // Timeout is part of configuration which defines a timeout
-// once the Duration is passed, machines tries to change to
-// one of the given states at On field
+// once the Duration is passed, Action is called if it is defined
+// and machines tries to change to one of the given states at On field.
+// OnNoMatch defines what happens if none of the targets can be selected,
+// by default the timeout is re-armed. A timeout without Targets is a
+// one-shot heartbeat, it calls its Action once, the machine stays in
+// the state and the timeout is not re-armed until the state is entered
+// again. If Cond is defined, the timeout is only armed if Cond passes
+// upon entering the state. If Jitter is defined, a random duration up
+// to Jitter is added to Duration every time the timeout is armed.
+// If RevertOnTimeout is set, Targets is ignored and the machine moves back
+// to the state it entered the current state from, which gives a transition
+// a deadline to be confirmed by a follow-up event. Duration must be positive,
+// a timeout which fires right away must opt in with Immediate and no Duration.
+// If DurationChoices is defined, it replaces Duration
 type Timeout struct {
+	Cond      func() bool
+	Duration  time.Duration
+	Jitter    time.Duration
+	Action    func()
+	Targets   Targets
+	OnNoMatch NoMatch
+	Fallback  func()
+
+	RevertOnTimeout bool
+	Immediate       bool
+	DurationChoices DurationChoices
+}
+
+// DurationChoices lets a timeout pick its duration every time it is armed,
+// a choice is picked with a probability proportional to its Weight using the
+// machine's Rand. A timeout with DurationChoices must not define Duration and
+// every choice must have a positive Duration and Weight
+type DurationChoices []struct {
 	Duration time.Duration
-	Targets  Targets
+	Weight   int
 }
 
-// States list of all state's
+// States list of all state's, upon entering a state, Entry is called and
+// then the first passing target of Always, if any, is taken right away without
+// waiting for an event. Otherwise Timeout and Timeouts are all armed
+// independently and the first one which changes the state cancels the others.
+// If SubMachine is defined, it is reset to its initial state upon entering the
+// state and events are offered to it first, only the events it doesn't handle
+// are handled by the state itself. Once SubMachine reaches a final state, a state
+// without any transitions, OnDone is sent to the machine asynchronously.
+// Timeouts cascade across the hierarchy, while the state is active both its own
+// timeouts and the ones of SubMachine's current state are armed, the first one to
+// fire moves the machine at its own level. Moving within SubMachine only re-arms
+// SubMachine's timeouts, the state's keep counting, and leaving the state cancels
+// the timeouts of both.
+// If EntryCtx is defined, it is called in a new goroutine after Entry and its
+// context is canceled once the machine leaves the state or is stopped, so long
+// running entry work can be aborted.
+// Tags label the state, so transitions can be attached to every state carrying
+// a tag with Config.TagOn and the current ones can be read with CurrentTags.
+// Replace is only used by MergeConfigs to replace a state instead of merging it
 type States []struct {
-	Ref     State
-	Timeout *Timeout
-	On      On
+	Ref        State
+	Replace    bool
+	Entry      func()
+	EntryCtx   func(ctx context.Context)
+	Always     Targets
+	Timeout    *Timeout
+	Timeouts   []*Timeout
+	SubMachine *Machine
+	OnDone     Event
+	Tags       []string
+	On         On
 }
 
-// Targets defines the next state, if Cond is defined, first it checks the Cond upon moving to state
+// Targets defines the next state, if Cond is defined, first it checks the Cond upon moving to state.
+// CondCtx is checked the same way and receives the context given to SendContext, so the same
+// configuration can be shared while the guards depend on request scoped values.
+// Cached is checked the same way and its result is cached on the machine.
+// If Async is defined, Target is an interim state, Async is called in a new goroutine
+// once the machine enters it and the machine moves on to the state given to done.
+// Label and Color only change how the transition is drawn by the exporters and
+// take precedence over the ones of the On entry
 type Targets []struct {
-	Cond   func() bool
-	Target State
+	Cond    func() bool
+	CondCtx func(ctx context.Context) bool
+	Cached  *CachedCond
+	Target  State
+	Async   func(done func(State))
+	Label   string
+	Color   string
 }
 
-// On defines all states related to given State
+// On defines all states related to given State, if Schedule is defined,
+// the transition only happens within the Schedule's window. If Count is
+// defined, the transition only happens on the Count-th matching event
+// received since entering the state. A transition targeting the current state
+// re-enters it, so Entry runs again and its timeouts are armed from scratch,
+// while a failing guard leaves the armed timeouts running untouched, which
+// makes a guarded self transition a keep-alive. Label replaces the generated
+// label of the transition's edges and Color colors them in the exporters.
+// If Pattern is set, Event is a pattern, see Event.Match, and the transition
+// handles the matching events the state has no transition of their own for,
+// Count then counts all of them
 type On []struct {
-	Event   Event
-	Cond    func() bool
-	Targets Targets
+	Event    Event
+	Cond     func() bool
+	CondCtx  func(ctx context.Context) bool
+	Cached   *CachedCond
+	Schedule *ScheduleSpec
+	Count    int
+	Targets  Targets
+	Label    string
+	Color    string
+	Pattern  bool
 }
 
 // Config defines the Machine's configuration
@@ -58,155 +223,1533 @@ type Config struct {
 	Initial      State
 	StateChanged func(prev State, next State)
 	States       States
+	// Clock is the source of time, if it is not set, the system clock is used.
+	// A Clock which is a MonotonicClock also drives the timeouts
+	Clock Clock
+	// Names is an optional registry of human readable names for states
+	Names map[State]string
+	// SettleThreshold is the longest timeout SendAndSettle waits for
+	SettleThreshold time.Duration
+	// Rand is used for every random decision, if it is not set,
+	// a package level source seeded with the current time is used
+	Rand Rand
+	// CacheGuards makes every guard run at most once during a single Send,
+	// guards are identified by their function value, so the same closure
+	// referenced in multiple places shares its result
+	CacheGuards bool
+	// Groups names sets of states, so they can be checked with InGroup
+	Groups map[string][]State
+	// GroupOn attaches transitions to every state of a group, a state's own
+	// transition for the same event takes precedence
+	GroupOn map[string]On
+	// TagOn attaches transitions to every state carrying the tag, a state's own
+	// or its group's transition for the same event takes precedence
+	TagOn map[string]On
+	// GlobalOn attaches transitions to every declared state, a state's own, its
+	// group's or its tags' transition for the same event takes precedence
+	GlobalOn On
+	// Warnings, if set, receives the problems which NewMachine tolerates,
+	// such as unreachable targets, instead of failing
+	Warnings func(err error)
+	// StepMode queues sent events, Always transitions and fired timeouts
+	// instead of applying them, so they can be applied one by one with Step
+	StepMode bool
+	// ActionTimeout, if set, is the longest an Entry, Action or Fallback may run.
+	// A slow action keeps running in the background, but the machine stops waiting
+	// for it and completes the transition, the transition is not rolled back and
+	// ErrActionTimeout is returned by Send and reported to the OnError handlers
+	ActionTimeout time.Duration
+	// OnTimeout, if set, is called for every transition taken by a timeout,
+	// after StateChanged and the transition hooks
+	OnTimeout func(from State, to State)
+	// ExpectContiguousFrom, if set, makes NewMachine check the declared states
+	// form a contiguous range starting from it, which catches a skipped state
+	// in generated enums
+	ExpectContiguousFrom *State
+	// StrictSend makes Send return ErrUnknownEvent instead of ErrNoop when the
+	// current state has no transition for the event, so an unexpected event can
+	// be told apart from one whose targets are all rejected by their guards
+	StrictSend bool
+	// OnGuardError, if set, is called when a guard panics, if it returns true, the
+	// machine moves to the returned state, such as an error state, and Send doesn't
+	// return the error, which is still reported to the OnError handlers
+	OnGuardError func(err error) (State, bool)
+	// EventAliases maps alternative names of events to their canonical event,
+	// an alias is replaced by its event before the machine handles it
+	EventAliases map[Event]Event
+	// AsyncCallbacks runs StateChanged, OnTimeout and the transition hooks in
+	// order in a separate goroutine, so a slow callback doesn't block the machine,
+	// the callbacks may then run after the machine has moved on. Stop ends the goroutine
+	AsyncCallbacks bool
+	// TargetSelection defines how a target is selected among the passing ones
+	TargetSelection TargetSelection
+	// RecordTimeline keeps every state the machine enters along with when it
+	// entered and left it, so the run can be exported with ToMermaidGantt.
+	// The timeline grows for as long as the machine runs
+	RecordTimeline bool
+	// RequireConfirm lists the events which take effect in two phases, Send
+	// rejects them with ErrConfirmRequired and they must be sent with SendPending
+	RequireConfirm map[Event]bool
+	// MaxLifetime, if set, bounds the lifetime of the machine, once it passes since
+	// the machine was created, the machine is forced into ExpireTarget whatever state
+	// it is in, as if a timeout fired, and a paused machine is resumed. The per-state
+	// timeouts keep running meanwhile and Stop cancels the lifetime
+	MaxLifetime time.Duration
+	// ExpireTarget is the state the machine is forced into once MaxLifetime passes
+	ExpireTarget State
+	// OnError, if set, is registered as the first OnError handler of the machine
+	OnError func(err error)
+	// RecordRejections, if set, is the number of the most recent rejected sends
+	// kept for Rejections
+	RecordRejections int
+	// OnTimeoutArmed, if set, is called for every timeout armed, with the state
+	// it belongs to and the duration it is armed for, including the re-arming of
+	// the timeouts by Resume
+	OnTimeoutArmed func(state State, d time.Duration)
+	// OnTimeoutCanceled, if set, is called for every armed timeout canceled before
+	// it fires, because its state is left, the machine is paused or stopped
+	OnTimeoutCanceled func(state State)
+	// StrictReachability makes NewMachine and Validate check every declared state
+	// can be reached from the initial state, guards are ignored
+	StrictReachability bool
+	// RateInterval, if set, counts the transitions taken in buckets of
+	// RateInterval, so TransitionRates can tell how often each one fires
+	RateInterval time.Duration
+	// RateRetention is how long the buckets are kept for, 60 intervals if it is not set
+	RateRetention time.Duration
+	// QueueDuringPending makes Send queue the events sent while a transition is
+	// outstanding, a transition sent with SendPending which is neither committed
+	// nor aborted or an Async target which hasn't called done yet. The queued
+	// events are applied in order once the transition settles, or a timeout or
+	// the like moves the machine out of its state, and their errors are reported
+	// to the OnError handlers, Send returns nil for them
+	QueueDuringPending bool
+	// Guards is an optional registry of the guards used by the configuration,
+	// exporters such as ToXState refer to the guards by their name in it
+	Guards GuardRegistry
+	// TransitionLog, if set, receives a line for every transition, such as
+	// "2021-01-01T12:00:00Z from=Closed to=Locked event=<timeout>", the event is
+	// <always> for Always and Async transitions. Each line is a single Write,
+	// a writer shared by multiple machines must be safe for concurrent use
+	TransitionLog io.Writer
+	// TimeoutCoalesce keeps the armed timeouts running when the machine re-enters
+	// the state it is in, instead of canceling and arming them from scratch, so
+	// rapid self transitions don't reset the timers. Entry still runs and the
+	// timeouts which are not armed, such as the one which just fired, are armed
+	TimeoutCoalesce bool
+	// CoalesceCallbacks calls StateChanged once per Send or fired timeout, with
+	// the state the machine was in before and the state it settled in, instead
+	// of once for every hop of a chain of Always transitions. It isn't called if
+	// the machine didn't move, but it is if the chain led back to where it started
+	CoalesceCallbacks bool
+	// Tracer, if set, starts a span for every Send and every fired timeout,
+	// recording the state it starts from, the event and the state the machine
+	// settles in as the fsm.from, fsm.event and fsm.to attributes. The span's
+	// context is passed to the CondCtx guards
+	Tracer Tracer
 }
 
+// maxAlwaysHops is the number of Always transitions which can be taken in a row
+// before the chain is considered a loop
+const maxAlwaysHops = 100
+
 type key struct {
 	Ref   State
 	Event Event
 }
 
 type stateInfo struct {
-	Timeout *Timeout
+	Entry      func()
+	EntryCtx   func(ctx context.Context)
+	Always     Targets
+	Timeouts   []*Timeout
+	SubMachine *Machine
+	OnDone     Event
+	Tags       []string
+	Events     []Event
+}
+
+type armedTimeout struct {
+	id       uint64
+	state    State
+	timeout  *Timeout
+	deadline time.Time
+	due      time.Duration
+	cancel   func()
 }
 
 type stateEventInfo struct {
-	Cond    func() bool
-	Targets Targets
+	Cond     func() bool
+	CondCtx  func(ctx context.Context) bool
+	Cached   *CachedCond
+	Schedule *ScheduleSpec
+	Count    int
+	Targets  Targets
+	Label    string
+	Color    string
+	Pattern  bool
 }
 
-// Machine is a main type which created using NewMachine and configured
+// Machine is a main type which created using NewMachine and configured,
+// it is safe to use Machine from multiple goroutines. Every transition, whether
+// caused by an event, an Always transition or a timeout, runs to completion
+// under a single lock, so observers such as State, AllowedEvents and Snapshot
+// never see a transition half done. Guards and actions run while the lock is
+// held, so they must not call back into the same machine. Callbacks, such as
+// StateChanged, the transition hooks and the OnError handlers, are called in
+// order once the lock is released, so they may use the machine, a Send made by
+// a callback runs to completion right away and returns its result
 type Machine struct {
-	currentState  State
-	states        map[State]*stateInfo
-	nextStates    map[key]*stateEventInfo
-	cancelTimeout func()
-	stateChanged  func(prev State, next State)
+	// counters are accessed atomically and kept first to be 64-bit aligned
+	transitions uint64
+	noops       uint64
+
+	mu             sync.Mutex
+	timeoutID      uint64
+	currentState   State
+	states         map[State]*stateInfo
+	nextStates     map[key]*stateEventInfo
+	timeouts       []*armedTimeout
+	stateChanged   func(prev State, next State)
+	clock          Clock
+	names          map[State]string
+	enteredAt      time.Time
+	hookID         uint64
+	edgeHooks      map[edge][]edgeHook
+	changed        chan struct{}
+	settle         time.Duration
+	eventCounts    map[Event]uint64
+	initial        State
+	rand           Rand
+	occurrences    map[Event]int
+	cacheGuards    bool
+	guardCache     map[unsafe.Pointer]bool
+	guardErr       error
+	actionTimeout  time.Duration
+	actionErr      error
+	onTimeout      func(from State, to State)
+	event          Event
+	observers      []chan Transition
+	stopped        bool
+	strictSend     bool
+	previous       State
+	entries        uint64
+	paused         []pausedTimeout
+	isPaused       bool
+	onGuardError   func(err error) (State, bool)
+	aliases        map[Event]Event
+	dispatcher     *dispatcher
+	disabled       map[key]bool
+	selection      TargetSelection
+	recordTimeline bool
+	timeline       []occupancy
+	cachedConds    map[string]cachedResult
+	requireConfirm map[Event]bool
+	ownsTables     bool
+	maxLifetime    time.Duration
+	expireTarget   State
+	expire         func()
+	rejections     []Rejection
+	rejectionNext  int
+	rejectionLimit int
+	onArmed        func(state State, d time.Duration)
+	onCanceled     func(state State)
+	warnings       func(err error)
+	contiguous     *State
+	strictReach    bool
+	rateInterval   time.Duration
+	rateRetention  time.Duration
+	rateBuckets    []rateBucket
+	cancelEntry    context.CancelFunc
+	queuePending   bool
+	pending        *PendingTransition
+	asyncEntry     uint64
+	queued         []queuedEvent
+	draining       bool
+	guards         GuardRegistry
+	pendingCalls   []func()
+	callMu         sync.Mutex
+	calls          []func()
+	calling        bool
+	transitionLog  io.Writer
+	coalesce       bool
+	tracer         Tracer
+	coalesceCalls  bool
+	batching       bool
+	batchMoved     bool
+	batchPrev      State
+	trail          *[]Transition
+	heartbeats     map[Event]*heartbeat
+	alwaysHops     int
+	errHandlers    []errorHandler
+	groups         map[string]map[State]bool
+	ctx            context.Context
+	stepMode       bool
+	stepping       bool
+	steps          []step
+	onFinal        func()
+	order          []State
 }
 
 // Send sends an event to machine, if nothing changes, ErrNoop will be return
 func (m *Machine) Send(evt Event) error {
-	key := key{m.currentState, evt}
-	stateEventInfo, ok := m.nextStates[key]
+	m.mu.Lock()
+	defer m.unlock()
+
+	return m.handle(evt)
+}
+
+// SendContext sends an event to machine like Send, ctx is passed to every
+// CondCtx guard evaluated during this transition
+func (m *Machine) SendContext(ctx context.Context, evt Event) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.ctx = ctx
+	defer func() {
+		m.ctx = nil
+	}()
+
+	return m.handle(evt)
+}
+
+// SendTimed sends an event to machine like Send and reports how long it took to
+// evaluate the guards and run the actions, the time spent waiting for other
+// transitions to finish and the timeouts armed by this transition are excluded
+func (m *Machine) SendTimed(evt Event) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	start := time.Now()
+	err := m.handle(evt)
+
+	return time.Since(start), err
+}
+
+// SendIf sends an event to machine like Send, but only if pred returns true for
+// the current state, the check and the send happen under the same lock, so no
+// other transition happens in between. If pred returns false, the event is not
+// sent and ErrCondFailed is returned. pred must not call back into the machine
+func (m *Machine) SendIf(evt Event, pred func(current State) bool) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if !pred(m.currentState) {
+		return ErrCondFailed
+	}
+
+	return m.handle(evt)
+}
+
+func (m *Machine) handle(evt Event) (err error) {
+	if canonical, ok := m.aliases[evt]; ok {
+		evt = canonical
+	}
+
+	if m.stopped {
+		return ErrStopped
+	}
+
+	m.armHeartbeat(evt)
+
+	if m.isPaused {
+		return ErrPaused
+	}
+
+	if m.requireConfirm[evt] {
+		return ErrConfirmRequired
+	}
+
+	if m.queuePending {
+		m.drainQueued()
+		if m.outstanding() {
+			m.queued = append(m.queued, queuedEvent{evt: evt, ctx: m.ctx})
+			return nil
+		}
+	}
+
+	if m.stepMode && !m.stepping {
+		m.steps = append(m.steps, step{evt: evt, ctx: m.ctx})
+		return nil
+	}
+
+	end := m.trace("fsm.send", evt)
+	defer func() {
+		end(err)
+	}()
+	defer m.batch()()
+
+	m.countEvent(evt)
+
+	m.event = evt
+	defer func() {
+		m.event = ""
+	}()
+
+	if m.cacheGuards {
+		m.guardCache = make(map[unsafe.Pointer]bool)
+		defer func() {
+			m.guardCache = nil
+		}()
+	}
+
+	m.guardErr = nil
+	m.actionErr = nil
+
+	from := m.currentState
+	err = m.send(evt)
+	if err == ErrNoop || err == ErrUnknownEvent {
+		atomic.AddUint64(&m.noops, 1)
+	}
+	m.recordRejection(from, evt, err)
+
+	if m.guardErr != nil {
+		err = m.guardErr
+		m.guardErr = nil
+		m.reportError(err)
+
+		if state, ok := m.guardFallback(err); ok {
+			err = m.process(state)
+		}
+	}
+
+	if m.actionErr != nil {
+		err = m.actionErr
+		m.actionErr = nil
+		m.reportError(err)
+	}
+
+	return err
+}
+
+func (m *Machine) send(evt Event) error {
+	if stateInfo, ok := m.states[m.currentState]; ok && stateInfo.SubMachine != nil {
+		err := stateInfo.SubMachine.SendContext(m.context(), evt)
+		if err != ErrNoop && err != ErrUnknownEvent {
+			return err
+		}
+	}
+
+	target, async, err := m.resolve(evt)
+	if err != nil {
+		return err
+	}
+
+	return m.enter(target, async)
+}
+
+// resolve evaluates the current state's transition for the event and returns
+// the selected target without taking it
+func (m *Machine) resolve(evt Event) (State, func(done func(State)), error) {
+	stateEventInfo, matched, ok := m.transition(key{m.currentState, evt})
 	if !ok {
-		return ErrNoop
+		if m.strictSend {
+			return 0, nil, ErrUnknownEvent
+		}
+		return 0, nil, ErrNoop
 	}
 
-	if stateEventInfo.Cond != nil && !stateEventInfo.Cond() {
-		return ErrCondFailed
+	if stateEventInfo.Schedule != nil && !stateEventInfo.Schedule.allows(m.clock.Now()) {
+		return 0, nil, ErrOutsideSchedule
 	}
 
-	for _, target := range stateEventInfo.Targets {
-		if target.Cond != nil && !target.Cond() {
-			continue
+	if !m.passes(stateEventInfo.Cond, stateEventInfo.CondCtx, stateEventInfo.Cached) {
+		return 0, nil, ErrCondFailed
+	}
+
+	if stateEventInfo.Count > 1 {
+		if m.occurrences == nil {
+			m.occurrences = make(map[Event]int)
+		}
+		// a pattern counts all the events it matches
+		m.occurrences[matched]++
+		if m.occurrences[matched] < stateEventInfo.Count {
+			return 0, nil, ErrCounting
 		}
+	}
+
+	i, err := m.selectTarget(stateEventInfo.Targets)
+	if err != nil {
+		return 0, nil, err
+	}
 
-		return m.process(target.Target)
+	if i < 0 {
+		return 0, nil, ErrNoop
 	}
 
-	return ErrNoop
+	target := stateEventInfo.Targets[i]
+
+	return target.Target, target.Async, nil
 }
 
 func (m *Machine) process(state State) error {
-	if m.cancelTimeout != nil {
-		m.cancelTimeout()
-		m.cancelTimeout = nil
+	if !m.coalesce || state != m.currentState || !m.armedFor(state) {
+		m.cancelTimeouts()
 	}
+	m.occurrences = nil
 
 	stateInfo, ok := m.states[state]
 	if !ok {
 		return ErrStateNotFound
 	}
 
+	m.entries++
+
+	m.haltSubMachine()
+	m.leaveEntry()
+
 	m.changeState(state, false)
+	m.enteredAt = m.clock.Now()
+	m.record()
 
-	if stateInfo.Timeout == nil {
-		// No timeout set, simply assing target to current
-		return nil
+	if stateInfo.Entry != nil {
+		m.runAction(stateInfo.Entry)
 	}
 
-	// need to setup timeout
-	m.cancelTimeout = setTimeout(func() {
-		for _, state := range stateInfo.Timeout.Targets {
-			if state.Cond != nil && !state.Cond() {
-				continue
-			}
-			// because timeout happens,
-			// we need to notify target even though
-			// state is the same
-			m.changeState(state.Target, true)
-			m.process(m.currentState)
-			break
+	if stateInfo.EntryCtx != nil {
+		var ctx context.Context
+		ctx, m.cancelEntry = context.WithCancel(context.Background())
+		go stateInfo.EntryCtx(ctx)
+	}
+
+	if stateInfo.SubMachine != nil {
+		stateInfo.SubMachine.reset()
+	}
+
+	i, err := m.selectTarget(stateInfo.Always)
+	if err != nil {
+		return err
+	}
+
+	if i >= 0 {
+		target := stateInfo.Always[i]
+
+		if m.stepMode {
+			m.steps = append(m.steps, step{target: target.Target, async: target.Async, always: true, entry: m.entries})
+			return nil
 		}
-	}, stateInfo.Timeout.Duration)
+
+		// guarded Always transitions can't be checked for loops upfront
+		if m.alwaysHops >= maxAlwaysHops {
+			return fmt.Errorf("state ref %d chains more than %d Always transitions: %w", state, maxAlwaysHops, ErrTransitionLoop)
+		}
+
+		m.alwaysHops++
+		defer func() {
+			m.alwaysHops--
+		}()
+
+		return m.enter(target.Target, target.Async)
+	}
+
+	m.armTimeouts(stateInfo)
+
+	if m.onFinal != nil && stateInfo.isFinal() {
+		m.onFinal()
+	}
 
 	return nil
 }
 
+// armTimeouts arms the timeouts of the state whose Cond passes,
+// the ones which are already armed keep running
+func (m *Machine) armTimeouts(stateInfo *stateInfo) {
+	for _, timeout := range stateInfo.Timeouts {
+		if m.isArmed(timeout) {
+			continue
+		}
+
+		if timeout.Cond != nil && !m.check(timeout.Cond) {
+			continue
+		}
+
+		m.armTimeout(timeout)
+	}
+}
+
+func (m *Machine) armTimeout(timeout *Timeout) {
+	duration := timeout.duration(m.rand)
+	if timeout.Jitter > 0 {
+		duration += time.Duration(m.rand.Int63n(int64(timeout.Jitter)))
+	}
+
+	m.armTimeoutFor(timeout, duration)
+}
+
+// armTimeoutFor arms the timeout to fire after the given duration
+func (m *Machine) armTimeoutFor(timeout *Timeout, duration time.Duration) {
+	// the id is used to ignore a timeout which fired
+	// while a newer transition was holding the lock
+	m.timeoutID++
+	id := m.timeoutID
+
+	if onArmed := m.onArmed; onArmed != nil {
+		state := m.currentState
+		m.callback(func() {
+			onArmed(state, duration)
+		})
+	}
+
+	m.timeouts = append(m.timeouts, &armedTimeout{
+		id:       id,
+		state:    m.currentState,
+		timeout:  timeout,
+		deadline: m.clock.Now().Add(duration),
+		due:      m.monotonic() + duration,
+		cancel: setTimeout(m.clock, func() {
+			m.mu.Lock()
+			defer m.unlock()
+
+			if !m.disarmTimeout(id) {
+				return
+			}
+
+			if m.stepMode {
+				m.steps = append(m.steps, step{timeout: timeout, entry: m.entries})
+				m.notify()
+				return
+			}
+
+			m.fireTimeout(timeout)
+		}, duration),
+	})
+}
+
+// disarmTimeout removes the armed timeout with given id,
+// it returns false if the timeout was already canceled
+func (m *Machine) disarmTimeout(id uint64) bool {
+	for i, armed := range m.timeouts {
+		if armed.id == id {
+			m.timeouts = append(m.timeouts[:i:i], m.timeouts[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Machine) cancelTimeouts() {
+	for _, armed := range m.timeouts {
+		armed.cancel()
+
+		if onCanceled := m.onCanceled; onCanceled != nil {
+			state := armed.state
+			m.callback(func() {
+				onCanceled(state)
+			})
+		}
+	}
+	m.timeouts = nil
+}
+
+// hasTimeoutWithin reports whether a timeout with a duration
+// less than or equal to threshold is armed
+func (m *Machine) hasTimeoutWithin(threshold time.Duration) bool {
+	for _, armed := range m.timeouts {
+		if armed.timeout.longest() <= threshold {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Machine) fireTimeout(timeout *Timeout) {
+	defer m.notify()
+	// the timeout may have left the state of an outstanding transition
+	defer m.drainQueued()
+
+	end := m.trace("fsm.timeout", "")
+	defer m.batch()()
+	m.guardErr = nil
+	m.actionErr = nil
+	defer func() {
+		err := m.guardErr
+		m.reportGuardErr()
+
+		if m.actionErr != nil {
+			err = m.actionErr
+			m.reportError(m.actionErr)
+			m.actionErr = nil
+		}
+		end(err)
+	}()
+
+	if timeout.Action != nil {
+		m.runAction(timeout.Action)
+	}
+
+	targets := m.timeoutTargets(timeout)
+	if len(targets) == 0 {
+		return
+	}
+
+	i, err := m.selectTarget(targets)
+	if err != nil {
+		m.reportError(err)
+		return
+	}
+
+	if i >= 0 {
+		state := targets[i]
+		// because timeout happens,
+		// we need to notify target even though
+		// state is the same
+		from := m.currentState
+		// the state is left before process runs, so its sub machine is halted here
+		m.haltSubMachine()
+		m.changeState(state.Target, true)
+		if onTimeout := m.onTimeout; onTimeout != nil {
+			target := state.Target
+			m.callback(func() {
+				onTimeout(from, target)
+			})
+		}
+		if err := m.enter(m.currentState, state.Async); err != nil {
+			m.reportError(err)
+		}
+		return
+	}
+
+	switch timeout.OnNoMatch {
+	case ReArm:
+		m.armTimeout(timeout)
+	case CallFallback:
+		if timeout.Fallback != nil {
+			m.runAction(timeout.Fallback)
+		}
+	}
+}
+
+// timeoutTargets returns the targets of the timeout, a reverting
+// timeout targets the state the current state was entered from
+func (m *Machine) timeoutTargets(timeout *Timeout) Targets {
+	if timeout.RevertOnTimeout {
+		return Targets{{Target: m.previous}}
+	}
+
+	return timeout.Targets
+}
+
 func (m *Machine) changeState(next State, byForce bool) {
-	if m.stateChanged != nil && (byForce || m.currentState != next) {
-		m.stateChanged(m.currentState, next)
+	if byForce || m.currentState != next {
+		if stateChanged := m.stateChanged; stateChanged != nil && !m.batching {
+			prev := m.currentState
+			m.callback(func() {
+				stateChanged(prev, next)
+			})
+		}
+		m.runEdgeHooks(m.currentState, next)
+		atomic.AddUint64(&m.transitions, 1)
+		transition := Transition{
+			From:    m.currentState,
+			To:      next,
+			Event:   m.event,
+			Timeout: byForce,
+		}
+		m.emit(transition)
+		if m.trail != nil {
+			*m.trail = append(*m.trail, transition)
+		}
+		m.countRate(TransitionDef{
+			From:    m.currentState,
+			Event:   m.event,
+			To:      next,
+			Timeout: byForce,
+		})
+		m.logTransition(m.currentState, next, byForce)
+		m.batchMoved = true
+		// only the first hop is caused by the event,
+		// the following ones are Always transitions
+		m.event = ""
+	}
+	if m.currentState != next {
+		m.previous = m.currentState
 	}
 	m.currentState = next
+	m.notify()
+}
+
+// selectTarget returns the index of the target to move to, or -1 if none passes
+func (m *Machine) selectTarget(targets Targets) (int, error) {
+	selected := -1
+	for i, target := range targets {
+		if !m.passes(target.Cond, target.CondCtx, target.Cached) {
+			continue
+		}
+
+		if m.selection != ExactlyOne {
+			return i, nil
+		}
+
+		if selected >= 0 {
+			return -1, fmt.Errorf("targets %s and %s both pass: %w", m.stateName(targets[selected].Target), m.stateName(target.Target), ErrAmbiguousTarget)
+		}
+		selected = i
+	}
+
+	return selected, nil
+}
+
+// passes reports whether both guards, if defined, pass, CondCtx
+// receives the context of the current SendContext
+func (m *Machine) passes(cond func() bool, condCtx func(ctx context.Context) bool, cached *CachedCond) bool {
+	if cond != nil && !m.check(cond) {
+		return false
+	}
+
+	if cached != nil && !m.checkCached(cached) {
+		return false
+	}
+
+	if condCtx != nil {
+		return m.guard(func() bool {
+			return condCtx(m.context())
+		})
+	}
+
+	return true
+}
+
+// context returns the context of the current SendContext
+func (m *Machine) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+
+	return m.ctx
+}
+
+func guarded(cond func() bool, condCtx func(ctx context.Context) bool, cached *CachedCond) bool {
+	return cond != nil || condCtx != nil || cached != nil
+}
+
+// check runs the guard, or returns its cached result
+// if guards are cached and it already ran during this Send
+func (m *Machine) check(cond func() bool) bool {
+	if m.guardCache == nil {
+		return m.guard(cond)
+	}
+
+	// a func value is a pointer to the function and its captured variables,
+	// so it identifies a single closure
+	id := funcID(cond)
+	if result, ok := m.guardCache[id]; ok {
+		return result
+	}
+
+	result := m.guard(cond)
+	m.guardCache[id] = result
+
+	return result
+}
+
+// funcID returns the pointer which identifies a func value
+func funcID(fn func() bool) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&fn))
+}
+
+// guard runs the guard and turns its panic into a failed guard, the
+// panic is kept in guardErr to be reported once the transition is done
+func (m *Machine) guard(cond func() bool) (result bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if m.guardErr == nil {
+				m.guardErr = fmt.Errorf("%v: %w", r, ErrGuardPanic)
+			}
+			result = false
+		}
+	}()
+
+	return cond()
+}
+
+// guardFallback returns the state OnGuardError chooses for the guard error
+func (m *Machine) guardFallback(err error) (State, bool) {
+	if m.onGuardError == nil {
+		return 0, false
+	}
+
+	return m.onGuardError(err)
+}
+
+// reportGuardErr reports the guard error of a transition which has no caller to
+// return it to and moves the machine to the state OnGuardError chooses, if any
+func (m *Machine) reportGuardErr() {
+	if m.guardErr == nil {
+		return
+	}
+
+	err := m.guardErr
+	m.guardErr = nil
+	m.reportError(err)
+
+	if state, ok := m.guardFallback(err); ok {
+		if err := m.process(state); err != nil {
+			m.reportError(err)
+		}
+	}
+}
+
+// notify wakes up everyone waiting for the machine to change
+func (m *Machine) notify() {
+	if m.changed != nil {
+		close(m.changed)
+		m.changed = nil
+	}
+}
+
+// wait returns a channel which is closed on the next change of the machine
+func (m *Machine) wait() <-chan struct{} {
+	if m.changed == nil {
+		m.changed = make(chan struct{})
+	}
+
+	return m.changed
 }
 
 // State returns the current state of machine
-func (m Machine) State() State {
+func (m *Machine) State() State {
+	m.mu.Lock()
+	defer m.unlock()
+
 	return m.currentState
 }
 
-// NewMachine creates a new machine
+// Stop cancels the armed timeouts and stops the machine where it is, the events
+// sent afterwards are rejected with ErrStopped. Calling Stop more than once is a no-op
+func (m *Machine) Stop() {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.stopped {
+		return
+	}
+
+	m.stopped = true
+	m.cancelTimeouts()
+	m.leaveEntry()
+	m.queued = nil
+
+	if m.expire != nil {
+		m.expire()
+		m.expire = nil
+	}
+	m.stopHeartbeats()
+
+	if m.dispatcher != nil {
+		m.dispatcher.close()
+	}
+
+	m.haltSubMachine()
+
+	for _, observer := range m.observers {
+		close(observer)
+	}
+	m.observers = nil
+
+	m.notify()
+}
+
+// AllowedEvents returns the events declared for the current state in the order
+// of declaration, guards and schedules are not evaluated
+func (m *Machine) AllowedEvents() []Event {
+	m.mu.Lock()
+	defer m.unlock()
+
+	return m.allowedEvents()
+}
+
+func (m *Machine) allowedEvents() []Event {
+	stateInfo, ok := m.states[m.currentState]
+	if !ok {
+		return nil
+	}
+
+	events := make([]Event, 0, len(stateInfo.Events))
+	for _, evt := range stateInfo.Events {
+		if !m.disabled[key{m.currentState, evt}] {
+			events = append(events, evt)
+		}
+	}
+
+	return events
+}
+
+func (m *Machine) stateName(state State) string {
+	if name, ok := m.names[state]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("%d", state)
+}
+
+// NewMachine creates a new machine, the initial state is entered like any other
+// state, so its Entry is called and its Always transitions are taken right away.
+// StateChanged is not called for the initial state itself, but it is called for
+// every hop the machine takes away from it
 func NewMachine(conf Config) (*Machine, error) {
-	if conf.Initial == 0 {
-		return nil, ErrInitialNotSet
+	compiled, err := compile(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	m := compiled.machine()
+	m.bindSubMachines()
+
+	m.mu.Lock()
+	err = m.process(conf.Initial)
+	if err == nil {
+		err = m.actionErr
+	}
+	m.actionErr = nil
+	m.armLifetime()
+	m.unlock()
+	if err != nil {
+		m.Stop()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// compile validates the configuration and builds the lookup tables
+// of the states and their transitions
+func compile(conf Config) (*CompiledConfig, error) {
+	compiled, errs := build(conf, false)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	return compiled, nil
+}
+
+// build validates the configuration and builds the lookup tables, it stops at
+// the first problem unless all is set, in which case it skips what it can't
+// build and keeps going so every problem is reported
+func build(conf Config, all bool) (*CompiledConfig, []error) {
+	var errs []error
+	fail := func(err ...error) bool {
+		errs = append(errs, err...)
+		return len(errs) > 0 && !all
+	}
+
+	if conf.Initial == 0 && fail(ErrInitialNotSet) {
+		return nil, errs
 	}
 
 	states := make(map[State]*stateInfo)
 	nextStates := make(map[key]*stateEventInfo)
+	order := make([]State, 0, len(conf.States))
+
+	// register adds the transitions to the given state, an already
+	// declared transition for the same event is kept unless override is set
+	register := func(ref State, on On, override bool) {
+		for _, nextState := range on {
+			k := key{ref, nextState.Event}
+			_, exists := nextStates[k]
+			if exists && !override {
+				continue
+			}
+			if !exists {
+				states[ref].Events = append(states[ref].Events, nextState.Event)
+			}
+
+			nextStates[k] = &stateEventInfo{
+				Cond:     nextState.Cond,
+				CondCtx:  nextState.CondCtx,
+				Cached:   nextState.Cached,
+				Schedule: nextState.Schedule,
+				Count:    nextState.Count,
+				Targets:  resolveSelf(ref, nextState.Targets),
+				Label:    nextState.Label,
+				Color:    nextState.Color,
+				Pattern:  nextState.Pattern,
+			}
+		}
+	}
 
 	for _, state := range conf.States {
+		if err := validateRef(state.Ref); err != nil {
+			if fail(err) {
+				return nil, errs
+			}
+			continue
+		}
+
 		if _, ok := states[state.Ref]; ok {
-			return nil, fmt.Errorf("duplicate state ref %d: %w", state.Ref, ErrDuplicateState)
+			if fail(fmt.Errorf("duplicate state ref %d: %w", state.Ref, ErrDuplicateState)) {
+				return nil, errs
+			}
+			continue
 		}
 
-		for _, nextState := range state.On {
-			nextStates[key{state.Ref, nextState.Event}] = &stateEventInfo{
-				Cond:    nextState.Cond,
-				Targets: nextState.Targets,
+		var timeouts []*Timeout
+		if state.Timeout != nil {
+			timeouts = append(timeouts, resolveSelfTimeout(state.Ref, state.Timeout))
+		}
+		for _, timeout := range state.Timeouts {
+			if timeout != nil {
+				timeouts = append(timeouts, resolveSelfTimeout(state.Ref, timeout))
 			}
 		}
 
+		order = append(order, state.Ref)
 		states[state.Ref] = &stateInfo{
-			Timeout: state.Timeout,
+			Entry:      state.Entry,
+			EntryCtx:   state.EntryCtx,
+			Always:     resolveSelf(state.Ref, state.Always),
+			Timeouts:   timeouts,
+			SubMachine: state.SubMachine,
+			OnDone:     state.OnDone,
+			Tags:       state.Tags,
 		}
+
+		register(state.Ref, state.On, true)
 	}
 
-	m := &Machine{
-		stateChanged: conf.StateChanged,
-		currentState: conf.Initial,
-		nextStates:   nextStates,
-		states:       states,
+	groups := make(map[string]map[State]bool)
+	for name, refs := range conf.Groups {
+		groups[name] = make(map[State]bool)
+		for _, ref := range refs {
+			if _, ok := states[ref]; !ok {
+				if fail(fmt.Errorf("group %s has unknown state %d: %w", name, ref, ErrStateNotFound)) {
+					return nil, errs
+				}
+				continue
+			}
+			groups[name][ref] = true
+		}
 	}
 
-	err := m.process(conf.Initial)
-	if err != nil {
-		return nil, err
+	for _, name := range sortedKeys(conf.GroupOn) {
+		refs, ok := conf.Groups[name]
+		if !ok {
+			if fail(fmt.Errorf("transitions for group %s: %w", name, ErrGroupNotFound)) {
+				return nil, errs
+			}
+			continue
+		}
+
+		for _, ref := range refs {
+			if _, ok := states[ref]; ok {
+				register(ref, conf.GroupOn[name], false)
+			}
+		}
 	}
 
-	return m, nil
+	for _, tag := range sortedKeys(conf.TagOn) {
+		for _, ref := range order {
+			if hasTag(states[ref].Tags, tag) {
+				register(ref, conf.TagOn[tag], false)
+			}
+		}
+	}
+
+	for _, ref := range order {
+		register(ref, conf.GlobalOn, false)
+	}
+
+	if conf.ExpectContiguousFrom != nil {
+		err := validateContiguous(*conf.ExpectContiguousFrom, states)
+		if err != nil && fail(err) {
+			return nil, errs
+		}
+	}
+
+	if fail(validateTargets(order, states, nextStates)...) {
+		return nil, errs
+	}
+
+	if fail(validateTimeouts(order, states)...) {
+		return nil, errs
+	}
+
+	err := validateLoops(states)
+	if err != nil && fail(err) {
+		return nil, errs
+	}
+
+	err = validateUnreachable(order, states, nextStates, conf.Warnings)
+	if err != nil && fail(err) {
+		return nil, errs
+	}
+
+	err = validateAliases(conf.EventAliases, states, nextStates)
+	if err != nil && fail(err) {
+		return nil, errs
+	}
+
+	if conf.StrictReachability {
+		err = validateReachable(conf.Initial, order, states, nextStates)
+		if err != nil && fail(err) {
+			return nil, errs
+		}
+	}
+
+	if conf.MaxLifetime < 0 && fail(fmt.Errorf("max lifetime of %s: %w", conf.MaxLifetime, ErrInvalidTimeout)) {
+		return nil, errs
+	}
+
+	if _, ok := states[conf.ExpireTarget]; conf.MaxLifetime > 0 && !ok && fail(fmt.Errorf("expire target %d: %w", conf.ExpireTarget, ErrStateNotFound)) {
+		return nil, errs
+	}
+
+	aliases := make(map[Event]Event, len(conf.EventAliases))
+	for alias, evt := range conf.EventAliases {
+		aliases[alias] = evt
+	}
+
+	return &CompiledConfig{
+		conf:       conf,
+		states:     states,
+		nextStates: nextStates,
+		order:      order,
+		groups:     groups,
+		aliases:    aliases,
+	}, errs
 }
 
-func setTimeout(fn func(), timeout time.Duration) func() {
-	cancel := make(chan struct{}, 1)
+// validateContiguous makes sure the states form a contiguous range starting from base
+func validateContiguous(base State, states map[State]*stateInfo) error {
+	refs := make([]State, 0, len(states))
+	for ref := range states {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i] < refs[j]
+	})
 
-	go func() {
-		select {
-		case <-time.After(timeout):
-			fn()
-		case <-cancel:
+	if len(refs) > 0 && refs[0] < base {
+		return fmt.Errorf("state %d is below %d: %w", refs[0], base, ErrNonContiguousStates)
+	}
+
+	// gaps are listed as ranges, so a large gap doesn't list every missing state
+	var gaps []string
+	next := base
+	for _, ref := range refs {
+		switch {
+		case ref == next+1:
+			gaps = append(gaps, fmt.Sprintf("%d", next))
+		case ref > next+1:
+			gaps = append(gaps, fmt.Sprintf("%d-%d", next, ref-1))
 		}
-	}()
+		next = ref + 1
+	}
+
+	if len(gaps) > 0 {
+		return fmt.Errorf("states %s are missing: %w", strings.Join(gaps, ", "), ErrNonContiguousStates)
+	}
+
+	return nil
+}
+
+// resolveSelf replaces Self in the targets with the state they are declared on,
+// the targets are copied only if they refer to Self
+func resolveSelf(ref State, targets Targets) Targets {
+	if !refersSelf(targets) {
+		return targets
+	}
+
+	resolved := make(Targets, len(targets))
+	copy(resolved, targets)
+	for i := range resolved {
+		if resolved[i].Target == Self {
+			resolved[i].Target = ref
+		}
+	}
+
+	return resolved
+}
+
+// resolveSelfTimeout returns the timeout with Self resolved in its targets,
+// the timeout is copied only if they refer to Self
+func resolveSelfTimeout(ref State, timeout *Timeout) *Timeout {
+	if !refersSelf(timeout.Targets) {
+		return timeout
+	}
+
+	resolved := *timeout
+	resolved.Targets = resolveSelf(ref, timeout.Targets)
+
+	return &resolved
+}
+
+func refersSelf(targets Targets) bool {
+	for _, target := range targets {
+		if target.Target == Self {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateRef makes sure the ref can be declared as a state, 0 stands for no
+// state and Self is reserved for the targets
+func validateRef(ref State) error {
+	if ref == 0 || ref == Self {
+		return fmt.Errorf("state ref %d: %w", ref, ErrInvalidState)
+	}
+
+	return nil
+}
 
-	return func() {
-		close(cancel)
+// validateTargets makes sure every target refers to a declared state,
+// all the dangling targets are reported
+func validateTargets(order []State, states map[State]*stateInfo, nextStates map[key]*stateEventInfo) []error {
+	var errs []error
+	check := func(ref State, targets Targets) {
+		for _, target := range targets {
+			if _, ok := states[target.Target]; !ok {
+				errs = append(errs, fmt.Errorf("state ref %d targets unknown state %d: %w", ref, target.Target, ErrStateNotFound))
+			}
+		}
 	}
+
+	for _, ref := range order {
+		stateInfo := states[ref]
+
+		check(ref, stateInfo.Always)
+
+		for _, evt := range stateInfo.Events {
+			check(ref, nextStates[key{ref, evt}].Targets)
+		}
+
+		for _, timeout := range stateInfo.Timeouts {
+			check(ref, timeout.Targets)
+		}
+	}
+
+	return errs
+}
+
+// validateTimeouts makes sure every timeout has a positive duration,
+// unless it explicitly fires right away, all the invalid timeouts are reported
+func validateTimeouts(order []State, states map[State]*stateInfo) []error {
+	var errs []error
+	for _, ref := range order {
+		for _, timeout := range states[ref].Timeouts {
+			if len(timeout.DurationChoices) > 0 {
+				if err := validateDurationChoices(timeout); err != nil {
+					errs = append(errs, fmt.Errorf("state ref %d %w", ref, err))
+				}
+				continue
+			}
+
+			switch {
+			case timeout.Immediate && timeout.Duration != 0:
+				errs = append(errs, fmt.Errorf("state ref %d has an immediate timeout with %s duration: %w", ref, timeout.Duration, ErrInvalidTimeout))
+			case !timeout.Immediate && timeout.Duration <= 0:
+				errs = append(errs, fmt.Errorf("state ref %d has a timeout with %s duration: %w", ref, timeout.Duration, ErrInvalidTimeout))
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateDurationChoices makes sure a timeout picks among positive durations only
+func validateDurationChoices(timeout *Timeout) error {
+	if timeout.Immediate || timeout.Duration != 0 {
+		return fmt.Errorf("has a timeout with both duration choices and a duration: %w", ErrInvalidTimeout)
+	}
+
+	for _, choice := range timeout.DurationChoices {
+		if choice.Duration <= 0 || choice.Weight <= 0 {
+			return fmt.Errorf("has a timeout choice of %s duration with %d weight: %w", choice.Duration, choice.Weight, ErrInvalidTimeout)
+		}
+	}
+
+	return nil
+}
+
+// validateAliases makes sure every alias refers to an event
+// handled by a state or one of the sub machines
+func validateAliases(aliases map[Event]Event, states map[State]*stateInfo, nextStates map[key]*stateEventInfo) error {
+	if len(aliases) == 0 {
+		return nil
+	}
+
+	handled := make(map[Event]bool)
+	for k := range nextStates {
+		handled[k.Event] = true
+	}
+
+	for _, stateInfo := range states {
+		if stateInfo.SubMachine == nil {
+			continue
+		}
+
+		sub := stateInfo.SubMachine
+		sub.mu.Lock()
+		for k := range sub.nextStates {
+			handled[k.Event] = true
+		}
+		sub.mu.Unlock()
+	}
+
+	names := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		names = append(names, string(alias))
+	}
+	sort.Strings(names)
+
+	for _, alias := range names {
+		evt := aliases[Event(alias)]
+		if !handled[evt] {
+			return fmt.Errorf("alias %s refers to event %s which no state handles: %w", alias, evt, ErrUnknownEvent)
+		}
+	}
+
+	return nil
+}
+
+// validateUnreachable makes sure no target follows an unconditional target,
+// if warn is set, such targets are reported to it instead
+func validateUnreachable(order []State, states map[State]*stateInfo, nextStates map[key]*stateEventInfo, warn func(error)) error {
+	check := func(ref State, kind string, targets Targets) error {
+		for i, target := range targets {
+			if guarded(target.Cond, target.CondCtx, target.Cached) || i == len(targets)-1 {
+				continue
+			}
+
+			err := fmt.Errorf("state ref %d %s targets %d after unconditional target %d: %w", ref, kind, targets[i+1].Target, target.Target, ErrUnreachableTarget)
+			if warn == nil {
+				return err
+			}
+			warn(err)
+			break
+		}
+		return nil
+	}
+
+	for _, ref := range order {
+		stateInfo := states[ref]
+
+		if err := check(ref, "always", stateInfo.Always); err != nil {
+			return err
+		}
+
+		for _, evt := range stateInfo.Events {
+			if err := check(ref, fmt.Sprintf("event %s", evt), nextStates[key{ref, evt}].Targets); err != nil {
+				return err
+			}
+		}
+
+		for _, timeout := range stateInfo.Timeouts {
+			if err := check(ref, "timeout", timeout.Targets); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateReachable makes sure every state can be reached from the initial state
+func validateReachable(initial State, order []State, states map[State]*stateInfo, nextStates map[key]*stateEventInfo) error {
+	reached := map[State]bool{initial: true}
+	queue := []State{initial}
+
+	visit := func(targets Targets) {
+		for _, target := range targets {
+			if !reached[target.Target] {
+				reached[target.Target] = true
+				queue = append(queue, target.Target)
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+
+		stateInfo, ok := states[ref]
+		if !ok {
+			continue
+		}
+
+		for _, evt := range stateInfo.Events {
+			visit(nextStates[key{ref, evt}].Targets)
+		}
+		visit(stateInfo.Always)
+		for _, timeout := range stateInfo.Timeouts {
+			visit(timeout.Targets)
+		}
+	}
+
+	for _, ref := range order {
+		if !reached[ref] {
+			return fmt.Errorf("state ref %d: %w", ref, ErrUnreachableState)
+		}
+	}
+
+	return nil
+}
+
+// validateLoops makes sure Always transitions and zero duration timeouts
+// which unconditionally move the machine don't lead back to where they
+// started and that timeouts are not re-armed over and over without any delay
+func validateLoops(states map[State]*stateInfo) error {
+	for ref, stateInfo := range states {
+		for _, timeout := range stateInfo.Timeouts {
+			if timeout.longest() > 0 || len(timeout.Targets) == 0 || timeout.OnNoMatch != ReArm {
+				continue
+			}
+			unconditional := false
+			for _, target := range timeout.Targets {
+				if !guarded(target.Cond, target.CondCtx, target.Cached) {
+					unconditional = true
+					break
+				}
+			}
+			if !unconditional {
+				return fmt.Errorf("state ref %d re-arms a zero duration timeout: %w", ref, ErrTransitionLoop)
+			}
+		}
+	}
+
+	next := func(ref State) (State, bool) {
+		stateInfo, ok := states[ref]
+		if !ok {
+			return 0, false
+		}
+
+		if always := stateInfo.Always; len(always) > 0 {
+			if guarded(always[0].Cond, always[0].CondCtx, always[0].Cached) {
+				return 0, false
+			}
+			return always[0].Target, true
+		}
+
+		for _, timeout := range stateInfo.Timeouts {
+			if timeout.longest() > 0 || len(timeout.Targets) == 0 {
+				continue
+			}
+			if timeout.Cond != nil || guarded(timeout.Targets[0].Cond, timeout.Targets[0].CondCtx, timeout.Targets[0].Cached) {
+				return 0, false
+			}
+			return timeout.Targets[0].Target, true
+		}
+		return 0, false
+	}
+
+	for ref := range states {
+		visited := map[State]bool{ref: true}
+		current := ref
+		for {
+			target, ok := next(current)
+			if !ok {
+				break
+			}
+			if visited[target] {
+				return fmt.Errorf("state ref %d: %w", ref, ErrTransitionLoop)
+			}
+			visited[target] = true
+			current = target
+		}
+	}
+
+	return nil
 }
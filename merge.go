@@ -0,0 +1,104 @@
+package fsm
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MergeConfigs combines two configs into one, so machines can be defined in parts.
+// States of both configs are kept, a state defined in both is merged by combining
+// their On transitions and taking every other field from the config which defines
+// it. If both define the same event or the same field for a state, ErrMergeConflict
+// is returned, unless the overlay's state sets Replace, in which case it replaces
+// the base's state entirely. For the rest of the config, maps are combined and any
+// other field defined by overlay, including Initial, takes precedence
+func MergeConfigs(base, overlay Config) (Config, error) {
+	merged := base
+
+	mergedValue := reflect.ValueOf(&merged).Elem()
+	overlayValue := reflect.ValueOf(overlay)
+
+	for i := 0; i < overlayValue.NumField(); i++ {
+		name := overlayValue.Type().Field(i).Name
+		field := overlayValue.Field(i)
+
+		switch {
+		case name == "States":
+			continue
+		case field.Kind() == reflect.Map && !field.IsNil():
+			combined := reflect.MakeMap(field.Type())
+			for _, m := range []reflect.Value{mergedValue.Field(i), field} {
+				iter := m.MapRange()
+				for iter.Next() {
+					combined.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+			mergedValue.Field(i).Set(combined)
+		case !field.IsZero():
+			mergedValue.Field(i).Set(field)
+		}
+	}
+
+	merged.States = make(States, len(base.States))
+	copy(merged.States, base.States)
+
+	index := make(map[State]int)
+	for i, state := range merged.States {
+		index[state.Ref] = i
+	}
+
+	for _, state := range overlay.States {
+		i, ok := index[state.Ref]
+		if !ok {
+			index[state.Ref] = len(merged.States)
+			merged.States = append(merged.States, state)
+			continue
+		}
+
+		if state.Replace {
+			merged.States[i] = state
+			continue
+		}
+
+		events := make(map[Event]bool)
+		for _, nextState := range merged.States[i].On {
+			events[nextState.Event] = true
+		}
+
+		on := make(On, len(merged.States[i].On), len(merged.States[i].On)+len(state.On))
+		copy(on, merged.States[i].On)
+		for _, nextState := range state.On {
+			if events[nextState.Event] {
+				return Config{}, fmt.Errorf("state ref %d defines event %s in both configs: %w", state.Ref, nextState.Event, ErrMergeConflict)
+			}
+			on = append(on, nextState)
+		}
+
+		err := mergeFields(reflect.ValueOf(&merged.States[i]).Elem(), reflect.ValueOf(state))
+		if err != nil {
+			return Config{}, fmt.Errorf("state ref %d %w", state.Ref, err)
+		}
+
+		merged.States[i].On = on
+	}
+
+	return merged, nil
+}
+
+// mergeFields copies every field of src which is defined to dst, Ref, Replace and On are skipped
+func mergeFields(dst, src reflect.Value) error {
+	for i := 0; i < src.NumField(); i++ {
+		name := src.Type().Field(i).Name
+		if name == "Ref" || name == "On" || name == "Replace" || src.Field(i).IsZero() {
+			continue
+		}
+
+		if !dst.Field(i).IsZero() {
+			return fmt.Errorf("defines %s in both configs: %w", name, ErrMergeConflict)
+		}
+
+		dst.Field(i).Set(src.Field(i))
+	}
+
+	return nil
+}
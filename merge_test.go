@@ -0,0 +1,95 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestMergeConfigs(t *testing.T) {
+	const (
+		EvtAlarm = fsm.Event("alarm")
+	)
+
+	const (
+		alarming fsm.State = iota + 10
+	)
+
+	overlay := fsm.Config{
+		Names: map[fsm.State]string{
+			alarming: "Alarming",
+		},
+		States: fsm.States{
+			{
+				Ref: locked,
+				On: fsm.On{
+					{
+						Event: EvtAlarm,
+						Targets: fsm.Targets{
+							{
+								Target: alarming,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: alarming,
+			},
+		},
+	}
+
+	conf, err := fsm.MergeConfigs(doorConfig(), overlay)
+	if err != nil {
+		t.Errorf("failed to merge configs: %s", err)
+		return
+	}
+
+	if conf.Initial != closed || conf.Names[closed] != "Closed" || conf.Names[alarming] != "Alarming" {
+		t.Errorf("expected initial state and names of both configs to be kept")
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	for _, evt := range []fsm.Event{evtLock, EvtAlarm} {
+		err = door.Send(evt)
+		if err != nil {
+			t.Errorf("failed to send %s: %s", evt, err)
+		}
+	}
+
+	if door.State() != alarming {
+		t.Errorf("expected %d state but got %d", alarming, door.State())
+	}
+
+	overlay.States[0].On[0].Event = evtUnlock
+	_, err = fsm.MergeConfigs(doorConfig(), overlay)
+	if !errors.Is(err, fsm.ErrMergeConflict) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrMergeConflict, err)
+	}
+
+	overlay.States[0].Replace = true
+	conf, err = fsm.MergeConfigs(doorConfig(), overlay)
+	if err != nil {
+		t.Errorf("failed to merge configs: %s", err)
+		return
+	}
+
+	door, err = fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	door.Send(evtLock)
+	door.Send(evtUnlock)
+
+	if door.State() != alarming {
+		t.Errorf("expected replaced unlock to move to %d state but got %d", alarming, door.State())
+	}
+}
@@ -0,0 +1,116 @@
+package fsm
+
+// TransitionDef is a declared transition of the machine, Event is empty for
+// Always transitions and transitions taken by a timeout, the latter have
+// Timeout set
+type TransitionDef struct {
+	From    State
+	Event   Event
+	To      State
+	Timeout bool
+}
+
+// EventsTo returns every declared transition, from any state, which targets the
+// given state, including Always transitions and timeouts. Transitions are listed
+// in the order of their states' declaration and guards are ignored
+func (m *Machine) EventsTo(target State) []TransitionDef {
+	m.mu.Lock()
+	defer m.unlock()
+
+	var defs []TransitionDef
+	for _, edge := range m.allEdges() {
+		if edge.To != target {
+			continue
+		}
+
+		defs = append(defs, edge.def())
+	}
+
+	return defs
+}
+
+func (e transitionEdge) def() TransitionDef {
+	return TransitionDef{
+		From:    e.From,
+		Event:   e.Event,
+		To:      e.To,
+		Timeout: e.IsTimeout,
+	}
+}
+
+// PathTo returns the shortest sequence of events which moves the machine from
+// one state to another, following event transitions only and ignoring their
+// guards, it returns false if there is no such sequence. Always transitions and
+// timeouts are not followed, so the path is only reliable for states without them
+func (m *Machine) PathTo(from, to State) ([]Event, bool) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	return events(m.pathTo(from, to, false))
+}
+
+// UnguardedPathTo works like PathTo but only follows transitions without guards,
+// so the returned sequence moves the machine regardless of the guards' results
+func (m *Machine) UnguardedPathTo(from, to State) ([]Event, bool) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	return events(m.pathTo(from, to, true))
+}
+
+// pathTo returns the shortest path of event edges between the states
+func (m *Machine) pathTo(from, to State, unguarded bool) ([]transitionEdge, bool) {
+	if _, ok := m.states[from]; !ok {
+		return nil, false
+	}
+
+	if from == to {
+		return []transitionEdge{}, true
+	}
+
+	steps := map[State]transitionEdge{from: {}}
+	queue := []State{from}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for _, edge := range m.edgesFrom(state) {
+			if edge.Event == "" || (unguarded && edge.Guarded) {
+				continue
+			}
+
+			if _, ok := steps[edge.To]; ok {
+				continue
+			}
+			steps[edge.To] = edge
+
+			if edge.To != to {
+				queue = append(queue, edge.To)
+				continue
+			}
+
+			var path []transitionEdge
+			for s := to; s != from; s = steps[s].From {
+				path = append([]transitionEdge{steps[s]}, path...)
+			}
+
+			return path, true
+		}
+	}
+
+	return nil, false
+}
+
+func events(path []transitionEdge, ok bool) ([]Event, bool) {
+	if !ok {
+		return nil, false
+	}
+
+	evts := make([]Event, 0, len(path))
+	for _, edge := range path {
+		evts = append(evts, edge.Event)
+	}
+
+	return evts, true
+}
@@ -0,0 +1,100 @@
+package fsm_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestEventsTo(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	expected := []fsm.TransitionDef{
+		{From: closed, Event: evtLock, To: locked},
+		{From: closed, To: locked, Timeout: true},
+		{From: unlocked, Event: evtLock, To: locked},
+	}
+
+	defs := door.EventsTo(locked)
+	if !reflect.DeepEqual(defs, expected) {
+		t.Errorf("expected %+v transitions, but got %+v", expected, defs)
+	}
+}
+
+func TestPathTo(t *testing.T) {
+	conf := doorConfig()
+	// unlocked opens the door only while the guard passes
+	conf.States[2].On[0].Cond = func() bool { return true }
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description  string
+		unguarded    bool
+		from         fsm.State
+		to           fsm.State
+		expectedPath []fsm.Event
+		expectedOk   bool
+	}{
+		{
+			description:  "locked to opened",
+			from:         locked,
+			to:           opened,
+			expectedPath: []fsm.Event{evtUnlock, evtOpen},
+			expectedOk:   true,
+		},
+		{
+			description:  "locked to opened without guards",
+			unguarded:    true,
+			from:         locked,
+			to:           opened,
+			expectedPath: nil,
+			expectedOk:   false,
+		},
+		{
+			description:  "opened to locked",
+			from:         opened,
+			to:           locked,
+			expectedPath: []fsm.Event{evtClose, evtLock},
+			expectedOk:   true,
+		},
+		{
+			description:  "already there",
+			from:         closed,
+			to:           closed,
+			expectedPath: []fsm.Event{},
+			expectedOk:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		pathTo := door.PathTo
+		if testCase.unguarded {
+			pathTo = door.UnguardedPathTo
+		}
+
+		path, ok := pathTo(testCase.from, testCase.to)
+		if ok != testCase.expectedOk || !reflect.DeepEqual(path, testCase.expectedPath) {
+			t.Errorf("in %s, expected %v (%t), but got %v (%t)", testCase.description, testCase.expectedPath, testCase.expectedOk, path, ok)
+		}
+	}
+
+	// replaying the path moves the door where it is expected
+	path, _ := door.PathTo(closed, unlocked)
+	for _, evt := range path {
+		door.Send(evt)
+	}
+
+	if door.State() != unlocked {
+		t.Errorf("expected %d state after replaying %v, but got %d", unlocked, path, door.State())
+	}
+}
@@ -0,0 +1,59 @@
+package fsm
+
+import "time"
+
+type pausedTimeout struct {
+	timeout   *Timeout
+	remaining time.Duration
+}
+
+// Pause freezes the machine, the armed timeouts are suspended with their
+// remaining time and the events sent are rejected with ErrPaused until Resume
+// is called. Pausing a paused or stopped machine is a no-op
+func (m *Machine) Pause() {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.isPaused || m.stopped {
+		return
+	}
+
+	for _, armed := range m.timeouts {
+		remaining := m.remaining(armed)
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		m.paused = append(m.paused, pausedTimeout{
+			timeout:   armed.timeout,
+			remaining: remaining,
+		})
+	}
+
+	m.cancelTimeouts()
+	m.isPaused = true
+}
+
+// Resume re-arms the suspended timeouts for their remaining time
+// and accepts events again
+func (m *Machine) Resume() {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if !m.isPaused {
+		return
+	}
+
+	m.isPaused = false
+
+	paused := m.paused
+	m.paused = nil
+
+	if m.stopped {
+		return
+	}
+
+	for _, p := range paused {
+		m.armTimeoutFor(p.timeout, p.remaining)
+	}
+}
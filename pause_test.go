@@ -0,0 +1,76 @@
+package fsm_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestPauseResume(t *testing.T) {
+	clock := newFakeClock(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	conf := doorConfig()
+	conf.Clock = clock
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	clock.Advance(6 * time.Second)
+	door.Pause()
+
+	if _, ok := door.PendingTimeoutTarget(); ok {
+		t.Errorf("expected no armed timeout while paused")
+	}
+
+	err = door.Send(evtOpen)
+	if err != fsm.ErrPaused {
+		t.Errorf("expected %s error while paused, but got %v", fsm.ErrPaused, err)
+	}
+
+	clock.Advance(time.Hour)
+	door.Resume()
+
+	description := door.Describe()
+	if !strings.Contains(description, "remaining 4s") {
+		t.Errorf("expected auto-lock to resume with 4s remaining, but got:\n%s", description)
+	}
+
+	err = door.Send(evtOpen)
+	if err != nil {
+		t.Errorf("expected events to be accepted once resumed, but got %s", err)
+	}
+}
+
+func TestPauseResumeFires(t *testing.T) {
+	conf := doorConfig()
+	conf.States[0].Timeout.Duration = 40 * time.Millisecond
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	door.Pause()
+	time.Sleep(60 * time.Millisecond)
+
+	if door.State() != closed {
+		t.Errorf("expected the door to stay %d while paused, but got %d", closed, door.State())
+	}
+
+	door.Resume()
+
+	ok := waitFor(time.Second, func() bool {
+		return door.State() == locked
+	})
+	if !ok {
+		t.Errorf("expected the door to lock once resumed, but got %d", door.State())
+	}
+}
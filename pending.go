@@ -0,0 +1,139 @@
+package fsm
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// PendingTransition is a transition whose guards have passed but which hasn't
+// been taken yet, it is taken by Commit or dropped by Abort
+type PendingTransition struct {
+	Event Event
+	From  State
+	To    State
+
+	m     *Machine
+	async func(done func(State))
+	entry uint64
+	done  bool
+}
+
+// SendPending sends an event to machine like Send, but only evaluates its guards
+// and returns the selected transition without taking it, so its actions run at
+// Commit. Events whose transitions require confirmation must be sent this way.
+// Only the machine's own transitions are considered, the event is not offered to
+// the current state's sub machine, and in step mode the event is not queued
+func (m *Machine) SendPending(evt Event) (*PendingTransition, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if canonical, ok := m.aliases[evt]; ok {
+		evt = canonical
+	}
+
+	if m.stopped {
+		return nil, ErrStopped
+	}
+
+	if m.isPaused {
+		return nil, ErrPaused
+	}
+
+	m.countEvent(evt)
+
+	if m.cacheGuards {
+		m.guardCache = make(map[unsafe.Pointer]bool)
+		defer func() {
+			m.guardCache = nil
+		}()
+	}
+
+	m.guardErr = nil
+
+	from := m.currentState
+	target, async, err := m.resolve(evt)
+	if err == ErrNoop || err == ErrUnknownEvent {
+		atomic.AddUint64(&m.noops, 1)
+	}
+	m.recordRejection(from, evt, err)
+
+	if m.guardErr != nil {
+		err = m.guardErr
+		m.guardErr = nil
+		m.reportError(err)
+
+		if state, ok := m.guardFallback(err); ok {
+			err = m.process(state)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &PendingTransition{
+		Event: evt,
+		From:  from,
+		To:    target,
+		m:     m,
+		async: async,
+		entry: m.entries,
+	}
+	m.pending = pending
+
+	return pending, nil
+}
+
+// Commit takes the pending transition and runs its actions, it returns
+// ErrPendingExpired if the transition was already committed or aborted, or if
+// the machine has entered a state since the transition was sent
+func (p *PendingTransition) Commit() error {
+	m := p.m
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.stopped {
+		return ErrStopped
+	}
+
+	if m.isPaused {
+		return ErrPaused
+	}
+
+	if p.done || m.entries != p.entry {
+		return ErrPendingExpired
+	}
+	p.done = true
+
+	m.event = p.Event
+	defer func() {
+		m.event = ""
+	}()
+
+	m.guardErr = nil
+	m.actionErr = nil
+
+	err := m.enter(p.To, p.async)
+
+	m.reportGuardErr()
+
+	if m.actionErr != nil {
+		err = m.actionErr
+		m.actionErr = nil
+		m.reportError(err)
+	}
+
+	m.drainQueued()
+
+	return err
+}
+
+// Abort drops the pending transition, the machine stays where it is
+func (p *PendingTransition) Abort() {
+	p.m.mu.Lock()
+	defer p.m.unlock()
+
+	p.done = true
+	p.m.drainQueued()
+}
@@ -0,0 +1,103 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestSendPending(t *testing.T) {
+	entered := 0
+
+	conf := doorConfig()
+	conf.RequireConfirm = map[fsm.Event]bool{evtOpen: true}
+	conf.States[3].Entry = func() {
+		entered++
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	err = door.Send(evtOpen)
+	if err != fsm.ErrConfirmRequired {
+		t.Errorf("expected %s error, but got %v", fsm.ErrConfirmRequired, err)
+	}
+
+	pending, err := door.SendPending(evtOpen)
+	if err != nil {
+		t.Errorf("failed to send pending open: %s", err)
+		return
+	}
+
+	if door.State() != closed || entered != 0 {
+		t.Errorf("expected the door to stay closed until commit, but got %d state and %d entries", door.State(), entered)
+	}
+
+	pending.Abort()
+
+	err = pending.Commit()
+	if err != fsm.ErrPendingExpired {
+		t.Errorf("expected %s error committing an aborted transition, but got %v", fsm.ErrPendingExpired, err)
+	}
+
+	if door.State() != closed || entered != 0 {
+		t.Errorf("expected abort to leave the door closed, but got %d state and %d entries", door.State(), entered)
+	}
+
+	pending, err = door.SendPending(evtOpen)
+	if err != nil {
+		t.Errorf("failed to send pending open: %s", err)
+		return
+	}
+
+	if pending.From != closed || pending.To != opened {
+		t.Errorf("expected a pending transition from %d to %d, but got %+v", closed, opened, pending)
+	}
+
+	err = pending.Commit()
+	if err != nil {
+		t.Errorf("failed to commit: %s", err)
+	}
+
+	if door.State() != opened || entered != 1 {
+		t.Errorf("expected commit to open the door, but got %d state and %d entries", door.State(), entered)
+	}
+
+	err = pending.Commit()
+	if err != fsm.ErrPendingExpired {
+		t.Errorf("expected %s error committing twice, but got %v", fsm.ErrPendingExpired, err)
+	}
+}
+
+func TestSendPendingExpires(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	pending, err := door.SendPending(evtOpen)
+	if err != nil {
+		t.Errorf("failed to send pending open: %s", err)
+		return
+	}
+
+	err = door.Send(evtLock)
+	if err != nil {
+		t.Errorf("failed to lock: %s", err)
+	}
+
+	err = pending.Commit()
+	if err != fsm.ErrPendingExpired {
+		t.Errorf("expected %s error once the door is locked, but got %v", fsm.ErrPendingExpired, err)
+	}
+
+	if door.State() != locked {
+		t.Errorf("expected %d state but got %d", locked, door.State())
+	}
+}
@@ -0,0 +1,37 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToPlantUML returns the transition graph of the machine as a PlantUML state
+// diagram, states are written using their names, timeouts are labeled with
+// their duration, guarded transitions are marked with [cond] and the
+// transitions' Label and Color are honored
+func (m *Machine) ToPlantUML() string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	var sb strings.Builder
+
+	sb.WriteString("@startuml\n")
+	fmt.Fprintf(&sb, "[*] --> %s\n", m.stateName(m.initial))
+
+	for _, edge := range m.allEdges() {
+		arrow := "-->"
+		if edge.Color != "" {
+			arrow = fmt.Sprintf("-[#%s]->", strings.TrimPrefix(edge.Color, "#"))
+		}
+
+		fmt.Fprintf(&sb, "%s %s %s", m.stateName(edge.From), arrow, m.stateName(edge.To))
+		if label := edge.label(); label != "" {
+			fmt.Fprintf(&sb, " : %s", label)
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("@enduml\n")
+
+	return sb.String()
+}
@@ -0,0 +1,36 @@
+package fsm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestToPlantUML(t *testing.T) {
+	conf := doorConfig()
+	conf.States[3].On[0].Cond = func() bool { return true }
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	uml := door.ToPlantUML()
+
+	expected := []string{
+		"@startuml\n",
+		"[*] --> Closed\n",
+		"Closed --> Locked : lock\n",
+		"Closed --> Locked : after 10s\n",
+		"Opened --> Closed : close [cond]\n",
+		"@enduml\n",
+	}
+
+	for _, value := range expected {
+		if !strings.Contains(uml, value) {
+			t.Errorf("expected PlantUML to contain %q, but got:\n%s", value, uml)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package fsm
+
+import "context"
+
+// queuedEvent is an event sent while a transition was outstanding
+type queuedEvent struct {
+	evt Event
+	ctx context.Context
+}
+
+// outstanding reports whether a pending or an Async transition
+// of the current state hasn't settled yet
+func (m *Machine) outstanding() bool {
+	if p := m.pending; p != nil && !p.done && p.entry == m.entries {
+		return true
+	}
+
+	return m.asyncEntry != 0 && m.asyncEntry == m.entries
+}
+
+// drainQueued applies the queued events in order until
+// one of them starts another outstanding transition
+func (m *Machine) drainQueued() {
+	if m.draining {
+		return
+	}
+
+	m.draining = true
+	ctx := m.ctx
+	defer func() {
+		m.draining = false
+		m.ctx = ctx
+	}()
+
+	for len(m.queued) > 0 && !m.stopped && !m.outstanding() {
+		queued := m.queued[0]
+		m.queued = m.queued[1:]
+
+		m.ctx = queued.ctx
+		m.reportSendErr(m.handle(queued.evt))
+	}
+}
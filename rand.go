@@ -0,0 +1,29 @@
+package fsm
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Rand is the source of randomness for every nondeterministic decision of
+// the machine, *rand.Rand satisfies it. The machine only uses it while holding
+// its lock, so a *rand.Rand must not be shared between machines
+type Rand interface {
+	Int63n(n int64) int64
+}
+
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.r.Int63n(n)
+}
+
+// defaultRand is shared by all the machines without a Config.Rand
+var defaultRand Rand = &lockedRand{r: rand.New(rand.NewSource(time.Now().UnixNano()))}
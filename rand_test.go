@@ -0,0 +1,55 @@
+package fsm_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestSeededRand(t *testing.T) {
+	run := func(seed int64) []string {
+		conf := trafficLightConfig(time.Hour)
+		conf.Clock = newFakeClock(time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC))
+		conf.Rand = rand.New(rand.NewSource(seed))
+		for i := range conf.States {
+			conf.States[i].Timeout.Jitter = time.Hour
+		}
+
+		light, err := fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("failed to initialized machine: %s", err)
+			return nil
+		}
+
+		descriptions := []string{light.Describe()}
+		for i := 0; i < 5; i++ {
+			light.Send(evtToggle)
+			descriptions = append(descriptions, light.Describe())
+		}
+
+		return descriptions
+	}
+
+	first := run(42)
+	second := run(42)
+	other := run(7)
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("expected identical runs at %d iteration, but got:\n%s\nand:\n%s", i, first[i], second[i])
+		}
+	}
+
+	same := true
+	for i := range first {
+		if first[i] != other[i] {
+			same = false
+		}
+	}
+
+	if same {
+		t.Errorf("expected runs with different seeds to differ")
+	}
+}
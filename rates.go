@@ -0,0 +1,76 @@
+package fsm
+
+import "time"
+
+// rateBucket counts the transitions taken during one RateInterval
+type rateBucket struct {
+	start  time.Time
+	counts map[TransitionDef]uint64
+}
+
+// TransitionRates returns how many times per second each transition was taken
+// during the trailing window, counted in whole buckets of Config.RateInterval,
+// so the window is rounded up to a multiple of it and ends where the bucket in
+// progress starts. The rates can't cover more than Config.RateRetention, it
+// returns nil if RateInterval is not set
+func (m *Machine) TransitionRates(window time.Duration) map[TransitionDef]float64 {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.rateInterval <= 0 || window <= 0 {
+		return nil
+	}
+
+	// the bucket in progress would count a fraction of an interval as a whole
+	end := m.clock.Now().Truncate(m.rateInterval)
+	span := (window + m.rateInterval - 1) / m.rateInterval * m.rateInterval
+	since := end.Add(-span)
+
+	rates := make(map[TransitionDef]float64)
+	for _, bucket := range m.rateBuckets {
+		if bucket.start.Before(since) || !bucket.start.Before(end) {
+			continue
+		}
+
+		for def, count := range bucket.counts {
+			rates[def] += float64(count)
+		}
+	}
+
+	for def := range rates {
+		rates[def] /= span.Seconds()
+	}
+
+	return rates
+}
+
+// countRate counts the transition in the current bucket and
+// drops the buckets older than the retention
+func (m *Machine) countRate(def TransitionDef) {
+	if m.rateInterval <= 0 {
+		return
+	}
+
+	now := m.clock.Now()
+	start := now.Truncate(m.rateInterval)
+
+	retention := m.rateRetention
+	if retention <= 0 {
+		retention = 60 * m.rateInterval
+	}
+
+	expired := 0
+	for expired < len(m.rateBuckets) && !m.rateBuckets[expired].start.After(now.Add(-retention)) {
+		expired++
+	}
+	m.rateBuckets = m.rateBuckets[expired:]
+
+	if last := len(m.rateBuckets) - 1; last < 0 || !m.rateBuckets[last].start.Equal(start) {
+		m.rateBuckets = append(m.rateBuckets, rateBucket{
+			start:  start,
+			counts: make(map[TransitionDef]uint64),
+		})
+	}
+
+	m.rateBuckets[len(m.rateBuckets)-1].counts[def]++
+}
@@ -0,0 +1,91 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestTransitionRates(t *testing.T) {
+	clock := newFakeClock(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	conf := doorConfig()
+	conf.Clock = clock
+	conf.RateInterval = time.Second
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	// the door is opened and closed once a second, and opened twice every other second
+	for i := 0; i < 20; i++ {
+		door.Send(evtOpen)
+		door.Send(evtClose)
+		if i%2 == 0 {
+			door.Send(evtOpen)
+			door.Send(evtClose)
+		}
+		clock.Advance(time.Second)
+	}
+
+	opening := fsm.TransitionDef{From: closed, Event: evtOpen, To: opened}
+	closing := fsm.TransitionDef{From: opened, Event: evtClose, To: closed}
+
+	testCases := []struct {
+		description string
+		advance     time.Duration
+		window      time.Duration
+		expected    float64
+	}{
+		{
+			description: "the trailing 10 seconds",
+			window:      10 * time.Second,
+			expected:    1.5,
+		},
+		{
+			description: "the trailing second",
+			window:      time.Second,
+			expected:    1,
+		},
+		{
+			description: "the trailing 10 seconds within a second",
+			advance:     700 * time.Millisecond,
+			window:      10 * time.Second,
+			expected:    1.5,
+		},
+		{
+			description: "the trailing second within a second",
+			window:      time.Second,
+			expected:    1,
+		},
+		{
+			description: "the trailing second and a half",
+			window:      1500 * time.Millisecond,
+			expected:    1.5,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.advance > 0 {
+			clock.Advance(testCase.advance)
+			door.Send(evtOpen)
+			door.Send(evtClose)
+		}
+
+		rates := door.TransitionRates(testCase.window)
+
+		for _, def := range []fsm.TransitionDef{opening, closing} {
+			if rates[def] != testCase.expected {
+				t.Errorf("in %s, expected %v rate for %+v, but got %v", testCase.description, testCase.expected, def, rates[def])
+			}
+		}
+
+		if len(rates) != 2 {
+			t.Errorf("in %s, expected only the open and close rates, but got %v", testCase.description, rates)
+		}
+	}
+}
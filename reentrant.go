@@ -0,0 +1,57 @@
+package fsm
+
+// callLater queues the callback to be called once the lock is released, so
+// callbacks can call back into the machine, it must be called with the lock held
+func (m *Machine) callLater(fn func()) {
+	m.pendingCalls = append(m.pendingCalls, fn)
+}
+
+// unlock releases the lock, then calls the callbacks queued meanwhile. The
+// callbacks are called one after another in the order they were queued, by the
+// goroutine which gets to them first, so a callback which sends an event gets
+// the send's result right away and the callbacks of that transition follow its own
+func (m *Machine) unlock() {
+	m.callMu.Lock()
+	m.calls = append(m.calls, m.pendingCalls...)
+	m.pendingCalls = nil
+
+	if m.calling || len(m.calls) == 0 {
+		m.callMu.Unlock()
+		m.mu.Unlock()
+		return
+	}
+
+	m.calling = true
+	m.callMu.Unlock()
+	m.mu.Unlock()
+
+	m.runCalls()
+}
+
+// runCalls calls the queued callbacks until there are none left, if a callback
+// panics, the remaining ones are called by the next unlock
+func (m *Machine) runCalls() {
+	finished := false
+	defer func() {
+		if !finished {
+			m.callMu.Lock()
+			m.calling = false
+			m.callMu.Unlock()
+		}
+	}()
+
+	for {
+		m.callMu.Lock()
+		if len(m.calls) == 0 {
+			m.calling = false
+			m.callMu.Unlock()
+			finished = true
+			return
+		}
+		fn := m.calls[0]
+		m.calls = m.calls[1:]
+		m.callMu.Unlock()
+
+		fn()
+	}
+}
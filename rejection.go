@@ -0,0 +1,54 @@
+package fsm
+
+import "time"
+
+// Rejection describes a sent event which didn't move the machine, Reason is
+// one of ErrNoop, ErrCondFailed and ErrUnknownEvent
+type Rejection struct {
+	State  State
+	Event  Event
+	Reason error
+	At     time.Time
+}
+
+// Rejections returns the most recent rejected sends kept by RecordRejections,
+// oldest first
+func (m *Machine) Rejections() []Rejection {
+	m.mu.Lock()
+	defer m.unlock()
+
+	return m.orderedRejections()
+}
+
+func (m *Machine) orderedRejections() []Rejection {
+	rejections := make([]Rejection, 0, len(m.rejections))
+	if len(m.rejections) == m.rejectionLimit {
+		rejections = append(rejections, m.rejections[m.rejectionNext:]...)
+		rejections = append(rejections, m.rejections[:m.rejectionNext]...)
+		return rejections
+	}
+
+	return append(rejections, m.rejections...)
+}
+
+// recordRejection keeps the rejected send, overwriting the oldest once the buffer is full
+func (m *Machine) recordRejection(state State, evt Event, err error) {
+	if m.rejectionLimit <= 0 || (err != ErrNoop && err != ErrCondFailed && err != ErrUnknownEvent) {
+		return
+	}
+
+	rejection := Rejection{
+		State:  state,
+		Event:  evt,
+		Reason: err,
+		At:     m.clock.Now(),
+	}
+
+	if len(m.rejections) < m.rejectionLimit {
+		m.rejections = append(m.rejections, rejection)
+		return
+	}
+
+	m.rejections[m.rejectionNext] = rejection
+	m.rejectionNext = (m.rejectionNext + 1) % m.rejectionLimit
+}
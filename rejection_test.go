@@ -0,0 +1,51 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestRejections(t *testing.T) {
+	clock := newFakeClock(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	conf := doorConfig()
+	conf.Clock = clock
+	conf.RecordRejections = 3
+	conf.StrictSend = true
+	conf.States[1].On[0].Cond = func() bool {
+		return false
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	for _, evt := range []fsm.Event{evtClose, evtLock, evtUnlock, evtOpen, evtUnlock} {
+		clock.Advance(time.Second)
+		door.Send(evt)
+	}
+
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	expected := []fsm.Rejection{
+		{State: locked, Event: evtUnlock, Reason: fsm.ErrCondFailed, At: start.Add(3 * time.Second)},
+		{State: locked, Event: evtOpen, Reason: fsm.ErrUnknownEvent, At: start.Add(4 * time.Second)},
+		{State: locked, Event: evtUnlock, Reason: fsm.ErrCondFailed, At: start.Add(5 * time.Second)},
+	}
+
+	rejections := door.Rejections()
+	if len(rejections) != len(expected) {
+		t.Errorf("expected %d rejections, but got %+v", len(expected), rejections)
+		return
+	}
+
+	for i := range expected {
+		if rejections[i] != expected[i] {
+			t.Errorf("expected %+v rejection at %d, but got %+v", expected[i], i, rejections[i])
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RequireHandles checks every declared state handles all the given events, so
+// protocol machines can't silently drop a message in some state. If any state
+// doesn't, the returned error wraps ErrMissingHandler and lists every missing
+// state and event pair. Group and global transitions count as handlers,
+// disabled transitions still do, as disabling is temporary
+func (m *Machine) RequireHandles(events ...Event) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	var missing []string
+	for _, ref := range m.order {
+		for _, evt := range events {
+			if _, ok := m.nextStates[key{ref, evt}]; !ok {
+				missing = append(missing, fmt.Sprintf("%s on %s", m.stateName(ref), evt))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("unhandled events %s: %w", strings.Join(missing, ", "), ErrMissingHandler)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package fsm_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestRequireHandles(t *testing.T) {
+	conf := doorConfig()
+	conf.GlobalOn = fsm.On{
+		{
+			Event: evtLock,
+			Targets: fsm.Targets{
+				{
+					Target: locked,
+				},
+			},
+		},
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	err = door.RequireHandles(evtLock)
+	if err != nil {
+		t.Errorf("expected every state to handle lock, but got %s", err)
+	}
+
+	err = door.RequireHandles(evtLock, evtOpen)
+	if !errors.Is(err, fsm.ErrMissingHandler) {
+		t.Errorf("expected %s error, but got %v", fsm.ErrMissingHandler, err)
+		return
+	}
+
+	for _, name := range []string{"Locked on open", "Opened on open"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected the error to name %q, but got %s", name, err)
+		}
+	}
+
+	if strings.Contains(err.Error(), "Closed") || strings.Contains(err.Error(), "Unlocked") {
+		t.Errorf("expected the error to only name the states missing open, but got %s", err)
+	}
+}
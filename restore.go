@@ -0,0 +1,85 @@
+package fsm
+
+// RestoreOptions defines how Restore puts the machine back into a state
+type RestoreOptions struct {
+	// ArmTimeouts arms the timeouts of the restored state from scratch, as if
+	// it was just entered, otherwise the machine stays in the state until an
+	// event moves it
+	ArmTimeouts bool
+}
+
+// Restore puts the machine back into a previously persisted state. Restoring is
+// not a transition, so neither Entry, EntryCtx, Always transitions nor StateChanged are
+// run, the armed timeouts of the current state are canceled and the restored
+// state's timeouts are armed only if opts.ArmTimeouts is set. A SubMachine of
+// the restored state starts over from its initial state
+func (m *Machine) Restore(state State, opts RestoreOptions) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.stopped {
+		return ErrStopped
+	}
+
+	if m.isPaused {
+		return ErrPaused
+	}
+
+	return m.restore(state, opts)
+}
+
+func (m *Machine) restore(state State, opts RestoreOptions) error {
+	stateInfo, ok := m.states[state]
+	if !ok {
+		return ErrStateNotFound
+	}
+
+	m.cancelTimeouts()
+	m.occurrences = nil
+
+	m.haltSubMachine()
+	m.leaveEntry()
+
+	m.currentState = state
+	m.previous = state
+	m.enteredAt = m.clock.Now()
+	m.record()
+	m.notify()
+
+	if stateInfo.SubMachine != nil {
+		stateInfo.SubMachine.reset()
+	}
+
+	if opts.ArmTimeouts {
+		m.armTimeouts(stateInfo)
+	}
+
+	return nil
+}
+
+// TrySequence sends the events in order and stops at the first one which fails,
+// ErrNoop included, in which case the machine is put back into the state it was
+// in before the sequence, like Restore with ArmTimeouts, and the failing error is
+// returned. No other transition happens in between. Putting the machine back is
+// not a transition, the Entry and other actions which already ran are not undone
+func (m *Machine) TrySequence(evts ...Event) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	start := m.currentState
+
+	for _, evt := range evts {
+		err := m.handle(evt)
+		if err == nil {
+			continue
+		}
+
+		if err != ErrStopped && err != ErrPaused && m.currentState != start {
+			m.restore(start, RestoreOptions{ArmTimeouts: true})
+		}
+
+		return err
+	}
+
+	return nil
+}
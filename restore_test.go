@@ -0,0 +1,82 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestRestore(t *testing.T) {
+	testCases := []struct {
+		description   string
+		armTimeouts   bool
+		expectedState fsm.State
+	}{
+		{
+			description:   "restored without timeouts",
+			armTimeouts:   false,
+			expectedState: closed,
+		},
+		{
+			description:   "restored with timeouts",
+			armTimeouts:   true,
+			expectedState: locked,
+		},
+	}
+
+	for _, testCase := range testCases {
+		conf := doorConfig()
+		conf.Initial = opened
+		conf.States[0].Timeout.Duration = 10 * time.Millisecond
+
+		door, err := fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("in %s, failed to create door fsm: %s", testCase.description, err)
+			continue
+		}
+
+		err = door.Restore(closed, fsm.RestoreOptions{ArmTimeouts: testCase.armTimeouts})
+		if err != nil {
+			t.Errorf("in %s, failed to restore door: %s", testCase.description, err)
+			continue
+		}
+
+		time.Sleep(30 * time.Millisecond)
+
+		if door.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, door.State())
+		}
+	}
+}
+
+func TestTrySequence(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	err = door.TrySequence(evtLock, evtUnlock, evtClose)
+	if err != fsm.ErrNoop {
+		t.Errorf("expected %s error closing the unlocked door, but got %v", fsm.ErrNoop, err)
+	}
+
+	if door.State() != closed {
+		t.Errorf("expected the door to be back in %d state, but got %d", closed, door.State())
+	}
+
+	if _, ok := door.CurrentTimeout(); !ok {
+		t.Errorf("expected the timeout of the closed door to be armed again")
+	}
+
+	err = door.TrySequence(evtLock, evtUnlock, evtOpen)
+	if err != nil {
+		t.Errorf("failed to apply the sequence: %s", err)
+	}
+
+	if door.State() != opened {
+		t.Errorf("expected %d state but got %d", opened, door.State())
+	}
+}
@@ -0,0 +1,41 @@
+package fsm
+
+// SendResult describes what happened to an event sent with SendEx. Matched is
+// set if the current state declares a transition for the event, GuardFailed
+// is set if such a transition was rejected by its guards and Changed is set
+// if the machine ended up in a different state
+type SendResult struct {
+	Changed     bool
+	From        State
+	To          State
+	Matched     bool
+	GuardFailed bool
+}
+
+// SendEx sends an event to machine like Send and describes the outcome, so
+// callers can tell an unmatched event from one rejected by a guard without
+// checking the returned error against the sentinel errors
+func (m *Machine) SendEx(evt Event) (SendResult, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	canonical := evt
+	if aliased, ok := m.aliases[evt]; ok {
+		canonical = aliased
+	}
+
+	from := m.currentState
+	_, _, matched := m.transition(key{from, canonical})
+
+	err := m.handle(evt)
+
+	result := SendResult{
+		From:    from,
+		To:      m.currentState,
+		Changed: from != m.currentState,
+		Matched: matched,
+	}
+	result.GuardFailed = err == ErrCondFailed || (matched && err == ErrNoop)
+
+	return result, err
+}
@@ -0,0 +1,105 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestSendEx(t *testing.T) {
+	allowed := false
+
+	conf := doorConfig()
+	conf.States[0].On[1].Cond = func() bool {
+		return allowed
+	}
+	conf.EventAliases = map[fsm.Event]fsm.Event{
+		"shut": evtClose,
+	}
+
+	testCases := []struct {
+		description string
+		event       fsm.Event
+		allowed     bool
+		sendError   error
+		expected    fsm.SendResult
+	}{
+		{
+			description: "guard rejects opening the closed door",
+			event:       evtOpen,
+			allowed:     false,
+			sendError:   fsm.ErrCondFailed,
+			expected: fsm.SendResult{
+				From:        closed,
+				To:          closed,
+				Matched:     true,
+				GuardFailed: true,
+			},
+		},
+		{
+			description: "closing the closed door is not matched",
+			event:       evtClose,
+			allowed:     true,
+			sendError:   fsm.ErrNoop,
+			expected: fsm.SendResult{
+				From: closed,
+				To:   closed,
+			},
+		},
+		{
+			description: "opening the closed door",
+			event:       evtOpen,
+			allowed:     true,
+			sendError:   nil,
+			expected: fsm.SendResult{
+				Changed: true,
+				From:    closed,
+				To:      opened,
+				Matched: true,
+			},
+		},
+		{
+			description: "closing the opened door by its alias",
+			event:       "shut",
+			allowed:     true,
+			sendError:   nil,
+			expected: fsm.SendResult{
+				Changed: true,
+				From:    opened,
+				To:      closed,
+				Matched: true,
+			},
+		},
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	for _, testCase := range testCases {
+		allowed = testCase.allowed
+
+		result, err := door.SendEx(testCase.event)
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %s, but got %s error", testCase.description, testCase.sendError, err)
+		}
+
+		if result != testCase.expected {
+			t.Errorf("in %s, expected %+v result, but got %+v", testCase.description, testCase.expected, result)
+		}
+	}
+
+	door.Pause()
+	result, err := door.SendEx("undeclared")
+	if err != fsm.ErrPaused || result.Matched {
+		t.Errorf("expected an unmatched %s result, but got %+v and %v", fsm.ErrPaused, result, err)
+	}
+
+	door.Stop()
+	result, err = door.SendEx("undeclared")
+	if err != fsm.ErrStopped || result.Matched {
+		t.Errorf("expected an unmatched %s result, but got %+v and %v", fsm.ErrStopped, result, err)
+	}
+}
@@ -0,0 +1,47 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// Run sends every event received from events to the machine until events is
+// closed or ctx is done, then stops the machine, so its timers don't outlive
+// the loop. The errors returned by Send, other than ErrNoop and ErrUnknownEvent,
+// are reported to the OnError handlers. Run returns ctx's error if ctx is done
+// and nil once events is closed
+func (m *Machine) Run(ctx context.Context, events <-chan Event) error {
+	defer m.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case evt, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			err := m.SendContext(ctx, evt)
+
+			m.mu.Lock()
+			m.reportSendErr(err)
+			m.unlock()
+		}
+	}
+}
+
+// reportSendErr reports the error of an event sent without a caller to return
+// it to, the events which are not handled are not errors
+func (m *Machine) reportSendErr(err error) {
+	if err == nil || err == ErrNoop || err == ErrUnknownEvent {
+		return
+	}
+
+	// guard panics and slow actions are already reported by handle
+	if errors.Is(err, ErrGuardPanic) || errors.Is(err, ErrActionTimeout) {
+		return
+	}
+
+	m.reportError(err)
+}
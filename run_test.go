@@ -0,0 +1,78 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestRun(t *testing.T) {
+	var states []fsm.State
+	var errs []error
+
+	conf := doorConfig()
+	conf.StateChanged = func(prev, next fsm.State) {
+		states = append(states, next)
+	}
+	conf.OnError = func(err error) {
+		errs = append(errs, err)
+	}
+	conf.States[1].On[0].Cond = func() bool {
+		return false
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	events := make(chan fsm.Event, 5)
+	for _, evt := range []fsm.Event{evtOpen, evtClose, evtLock, evtUnlock, evtOpen} {
+		events <- evt
+	}
+	close(events)
+
+	err = door.Run(context.Background(), events)
+	if err != nil {
+		t.Errorf("expected run to end without error once events is closed, but got %s", err)
+	}
+
+	expected := []fsm.State{opened, closed, locked}
+	if len(states) != len(expected) {
+		t.Errorf("expected %v states, but got %v", expected, states)
+	} else {
+		for i := range expected {
+			if states[i] != expected[i] {
+				t.Errorf("expected %v states, but got %v", expected, states)
+				break
+			}
+		}
+	}
+
+	if len(errs) != 1 || errs[0] != fsm.ErrCondFailed {
+		t.Errorf("expected only the rejected unlock to be reported, but got %v", errs)
+	}
+
+	err = door.Send(evtUnlock)
+	if err != fsm.ErrStopped {
+		t.Errorf("expected %s error once run is done, but got %v", fsm.ErrStopped, err)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = door.Run(ctx, make(chan fsm.Event))
+	if err != context.Canceled {
+		t.Errorf("expected %s error, but got %v", context.Canceled, err)
+	}
+}
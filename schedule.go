@@ -0,0 +1,43 @@
+package fsm
+
+import "time"
+
+// ScheduleSpec limits a transition to a time window. Weekdays is the list of
+// allowed days, an empty list allows every day. Start and End are wall clock
+// times given as offsets from midnight, so the window doesn't move on the days
+// daylight saving time starts or ends, if End is zero the window lasts until the end of the day and if
+// Start is after End the window wraps around midnight
+type ScheduleSpec struct {
+	Weekdays []time.Weekday
+	Start    time.Duration
+	End      time.Duration
+}
+
+func (s *ScheduleSpec) allows(now time.Time) bool {
+	if len(s.Weekdays) > 0 {
+		found := false
+		for _, day := range s.Weekdays {
+			if day == now.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	offset := time.Duration(now.Hour())*time.Hour +
+		time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second +
+		time.Duration(now.Nanosecond())
+
+	switch {
+	case s.End == 0:
+		return offset >= s.Start
+	case s.Start <= s.End:
+		return offset >= s.Start && offset < s.End
+	default:
+		return offset >= s.Start || offset < s.End
+	}
+}
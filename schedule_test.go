@@ -0,0 +1,181 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestScheduledTransition(t *testing.T) {
+	const (
+		EvtApprove = fsm.Event("approve")
+	)
+
+	const (
+		_ fsm.State = iota
+		pending
+		approved
+	)
+
+	// 2021-01-02 is a Saturday
+	clock := newFakeClock(time.Date(2021, 1, 2, 10, 0, 0, 0, time.UTC))
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial: pending,
+		Clock:   clock,
+		States: fsm.States{
+			{
+				Ref: pending,
+				On: fsm.On{
+					{
+						Event: EvtApprove,
+						Schedule: &fsm.ScheduleSpec{
+							Weekdays: []time.Weekday{
+								time.Monday,
+								time.Tuesday,
+								time.Wednesday,
+								time.Thursday,
+								time.Friday,
+							},
+							Start: 9 * time.Hour,
+							End:   17 * time.Hour,
+						},
+						Targets: fsm.Targets{
+							{
+								Target: approved,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: approved,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description   string
+		now           time.Time
+		sendError     error
+		expectedState fsm.State
+	}{
+		{
+			description:   "approving on saturday",
+			now:           time.Date(2021, 1, 2, 10, 0, 0, 0, time.UTC),
+			sendError:     fsm.ErrOutsideSchedule,
+			expectedState: pending,
+		},
+		{
+			description:   "approving on monday before working hours",
+			now:           time.Date(2021, 1, 4, 8, 59, 0, 0, time.UTC),
+			sendError:     fsm.ErrOutsideSchedule,
+			expectedState: pending,
+		},
+		{
+			description:   "approving on monday after working hours",
+			now:           time.Date(2021, 1, 4, 17, 0, 0, 0, time.UTC),
+			sendError:     fsm.ErrOutsideSchedule,
+			expectedState: pending,
+		},
+		{
+			description:   "approving on monday within working hours",
+			now:           time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC),
+			sendError:     nil,
+			expectedState: approved,
+		},
+	}
+
+	for _, testCase := range testCases {
+		clock.Set(testCase.now)
+
+		err = m.Send(EvtApprove)
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %s, but got %s error", testCase.description, testCase.sendError, err)
+		}
+
+		if m.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, m.State())
+		}
+	}
+}
+
+func TestScheduleDaylightSaving(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		pending
+		approved
+	)
+
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Errorf("failed to load location: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description string
+		now         time.Time
+		sendError   error
+	}{
+		{
+			description: "approving before working hours as daylight saving starts",
+			now:         time.Date(2021, 3, 14, 9, 30, 0, 0, location),
+			sendError:   fsm.ErrOutsideSchedule,
+		},
+		{
+			description: "approving within working hours as daylight saving starts",
+			now:         time.Date(2021, 3, 14, 10, 30, 0, 0, location),
+		},
+		{
+			description: "approving after working hours as daylight saving ends",
+			now:         time.Date(2021, 11, 7, 17, 30, 0, 0, location),
+			sendError:   fsm.ErrOutsideSchedule,
+		},
+		{
+			description: "approving within working hours as daylight saving ends",
+			now:         time.Date(2021, 11, 7, 16, 30, 0, 0, location),
+		},
+	}
+
+	for _, testCase := range testCases {
+		m, err := fsm.NewMachine(fsm.Config{
+			Initial: pending,
+			Clock:   newFakeClock(testCase.now),
+			States: fsm.States{
+				{
+					Ref: pending,
+					On: fsm.On{
+						{
+							Event: "approve",
+							Schedule: &fsm.ScheduleSpec{
+								Start: 10 * time.Hour,
+								End:   17 * time.Hour,
+							},
+							Targets: fsm.Targets{{Target: approved}},
+						},
+					},
+				},
+				{Ref: approved},
+			},
+		})
+		if err != nil {
+			t.Errorf("in %s, failed to initialized machine: %s", testCase.description, err)
+			continue
+		}
+
+		err = m.Send("approve")
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %v, but got %v error", testCase.description, testCase.sendError, err)
+		}
+
+		m.Stop()
+	}
+}
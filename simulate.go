@@ -0,0 +1,70 @@
+package fsm
+
+import (
+	"math/rand"
+	"unsafe"
+)
+
+// Simulate drives the machine through random transitions for load and soak
+// testing. At each step it sends one of the allowed events picked at random
+// among the ones whose guards pass or which count towards the transition's
+// Count. The guards are checked without sending the events, so the others are
+// neither counted nor recorded as rejected. It stops early once no allowed event
+// passes, such as in a final state, or the machine is stopped. It returns every transition taken, Always transitions included.
+// The machine is locked for the whole simulation, so timeouts only fire after it
+func Simulate(m *Machine, steps int, r *rand.Rand) []Transition {
+	m.mu.Lock()
+	defer m.unlock()
+
+	var transitions []Transition
+	m.trail = &transitions
+	defer func() {
+		m.trail = nil
+	}()
+
+	for i := 0; i < steps && !m.stopped; i++ {
+		events := m.allowedEvents()
+
+		picked := -1
+		for _, j := range r.Perm(len(events)) {
+			if m.wouldPass(events[j]) {
+				picked = j
+				break
+			}
+		}
+
+		if picked < 0 {
+			break
+		}
+
+		m.handle(events[picked])
+	}
+
+	return transitions
+}
+
+// wouldPass reports whether sending the event would take its transition or
+// count towards its Count, the guards run but nothing else is changed
+func (m *Machine) wouldPass(evt Event) bool {
+	if m.requireConfirm[evt] {
+		return false
+	}
+
+	restore := m.keepOccurrences()
+	defer restore()
+
+	if m.cacheGuards {
+		m.guardCache = make(map[unsafe.Pointer]bool)
+		defer func() {
+			m.guardCache = nil
+		}()
+	}
+
+	m.guardErr = nil
+	_, _, err := m.resolve(evt)
+
+	panicked := m.guardErr != nil
+	m.guardErr = nil
+
+	return !panicked && (err == nil || err == ErrCounting)
+}
@@ -0,0 +1,76 @@
+package fsm_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestSimulate(t *testing.T) {
+	const steps = 20
+
+	light, err := fsm.NewMachine(trafficLightConfig(time.Hour))
+	if err != nil {
+		t.Errorf("failed to create traffic light fsm: %s", err)
+		return
+	}
+	defer light.Stop()
+
+	declared := make(map[fsm.Transition]bool)
+	light.Walk(func(from fsm.State, evt fsm.Event, to fsm.State, isTimeout bool) bool {
+		if !isTimeout {
+			declared[fsm.Transition{From: from, To: to, Event: evt}] = true
+		}
+		return true
+	})
+
+	transitions := fsm.Simulate(light, steps, rand.New(rand.NewSource(1)))
+	if len(transitions) != steps {
+		t.Errorf("expected %d transitions, but got %d", steps, len(transitions))
+	}
+
+	from := red
+	for i, transition := range transitions {
+		if !declared[transition] {
+			t.Errorf("expected transition %d to be declared, but got %+v", i, transition)
+		}
+
+		if transition.From != from {
+			t.Errorf("expected transition %d to start from %d, but got %d", i, from, transition.From)
+		}
+		from = transition.To
+	}
+
+	if light.State() != from {
+		t.Errorf("expected the light to end in %d state, but got %d", from, light.State())
+	}
+}
+
+func TestSimulateRejectedEvents(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		conf := doorConfig()
+		conf.RecordRejections = 5
+		conf.States[0].On[1].Cond = func() bool {
+			return false
+		}
+
+		door, err := fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("failed to create door fsm: %s", err)
+			return
+		}
+
+		transitions := fsm.Simulate(door, 1, rand.New(rand.NewSource(seed)))
+		if len(transitions) != 1 || transitions[0].Event != evtLock {
+			t.Errorf("with seed %d, expected the door to lock, but got %+v", seed, transitions)
+		}
+
+		if door.EventCount(evtOpen) != 0 || door.NoopCount() != 0 || len(door.Rejections()) != 0 {
+			t.Errorf("with seed %d, expected the rejected open not to be sent, but got %d opens, %d noops and %v", seed, door.EventCount(evtOpen), door.NoopCount(), door.Rejections())
+		}
+
+		door.Stop()
+	}
+}
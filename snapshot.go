@@ -0,0 +1,54 @@
+package fsm
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// MachineSnapshot is a consistent view of the machine at a single moment
+type MachineSnapshot struct {
+	State         State
+	EnteredAt     time.Time
+	Transitions   uint64
+	Noops         uint64
+	EventCounts   map[Event]uint64
+	AllowedEvents []Event
+}
+
+// Snapshot returns the current state, when it was entered, the counters and
+// the allowed events, all captured at once, so they never disagree with each
+// other even while other goroutines are sending events
+func (m *Machine) Snapshot() MachineSnapshot {
+	m.mu.Lock()
+	defer m.unlock()
+
+	return m.snapshot()
+}
+
+// SnapshotAndReset returns a snapshot like Snapshot and sets the counters back to
+// zero at the same moment, so polling it reports every transition exactly once
+func (m *Machine) SnapshotAndReset() MachineSnapshot {
+	m.mu.Lock()
+	defer m.unlock()
+
+	snapshot := m.snapshot()
+	m.resetCounters()
+
+	return snapshot
+}
+
+func (m *Machine) snapshot() MachineSnapshot {
+	eventCounts := make(map[Event]uint64, len(m.eventCounts))
+	for evt, count := range m.eventCounts {
+		eventCounts[evt] = count
+	}
+
+	return MachineSnapshot{
+		State:         m.currentState,
+		EnteredAt:     m.enteredAt,
+		Transitions:   atomic.LoadUint64(&m.transitions),
+		Noops:         atomic.LoadUint64(&m.noops),
+		EventCounts:   eventCounts,
+		AllowedEvents: m.allowedEvents(),
+	}
+}
@@ -0,0 +1,84 @@
+package fsm_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestSnapshotConsistency(t *testing.T) {
+	const (
+		senders = 4
+		sends   = 500
+	)
+
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	allowed := map[fsm.State]int{
+		unlocked: 2,
+		closed:   2,
+		opened:   1,
+		locked:   1,
+	}
+
+	// without guards every event sent to the door either moves it or is a noop
+	check := func(snapshot fsm.MachineSnapshot) {
+		var sent uint64
+		for _, count := range snapshot.EventCounts {
+			sent += count
+		}
+
+		if sent != snapshot.Transitions+snapshot.Noops {
+			t.Errorf("expected %d sent events to match %d transitions and %d noops", sent, snapshot.Transitions, snapshot.Noops)
+		}
+
+		if len(snapshot.AllowedEvents) != allowed[snapshot.State] {
+			t.Errorf("expected %d allowed events in %d state, but got %v", allowed[snapshot.State], snapshot.State, snapshot.AllowedEvents)
+		}
+	}
+
+	events := []fsm.Event{evtOpen, evtClose, evtLock, evtUnlock}
+
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < sends; j++ {
+				door.Send(events[(i+j)%len(events)])
+			}
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var total uint64
+	for polling := true; polling; {
+		select {
+		case <-done:
+			polling = false
+		default:
+		}
+
+		check(door.Snapshot())
+
+		snapshot := door.SnapshotAndReset()
+		check(snapshot)
+		total += snapshot.Transitions + snapshot.Noops
+	}
+
+	if total != senders*sends {
+		t.Errorf("expected the reset snapshots to report %d events, but got %d", senders*sends, total)
+	}
+}
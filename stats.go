@@ -0,0 +1,63 @@
+package fsm
+
+// GraphStats describes the complexity of a machine's transition graph. Every
+// target of an event, Always or timeout transition counts as a transition.
+// AverageOutDegree is the number of transitions per state and MaxDepth is the
+// number of hops needed to reach the farthest state from the initial state
+type GraphStats struct {
+	States           int
+	Transitions      int
+	SelfLoops        int
+	TerminalStates   int
+	AverageOutDegree float64
+	MaxDepth         int
+}
+
+// GraphStats computes the complexity metrics of the declared transitions,
+// guards are ignored
+func (m *Machine) GraphStats() GraphStats {
+	m.mu.Lock()
+	defer m.unlock()
+
+	stats := GraphStats{
+		States: len(m.order),
+	}
+
+	for _, ref := range m.order {
+		if m.states[ref].isFinal() {
+			stats.TerminalStates++
+		}
+	}
+
+	for _, edge := range m.allEdges() {
+		stats.Transitions++
+		if edge.From == edge.To {
+			stats.SelfLoops++
+		}
+	}
+
+	if stats.States > 0 {
+		stats.AverageOutDegree = float64(stats.Transitions) / float64(stats.States)
+	}
+
+	depths := map[State]int{m.initial: 0}
+	queue := []State{m.initial}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for _, edge := range m.edgesFrom(state) {
+			if _, ok := depths[edge.To]; ok {
+				continue
+			}
+
+			depths[edge.To] = depths[state] + 1
+			if depths[edge.To] > stats.MaxDepth {
+				stats.MaxDepth = depths[edge.To]
+			}
+			queue = append(queue, edge.To)
+		}
+	}
+
+	return stats
+}
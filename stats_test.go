@@ -0,0 +1,29 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestGraphStats(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	expected := fsm.GraphStats{
+		States:           4,
+		Transitions:      7,
+		SelfLoops:        0,
+		TerminalStates:   0,
+		AverageOutDegree: 1.75,
+		MaxDepth:         2,
+	}
+
+	stats := door.GraphStats()
+	if stats != expected {
+		t.Errorf("expected %+v stats, but got %+v", expected, stats)
+	}
+}
@@ -0,0 +1,65 @@
+package fsm
+
+import "context"
+
+// step is a queued piece of work of a machine in step mode, either an event,
+// an Always transition or a fired timeout, the latter two belong to the entry
+// of the state they were queued in
+type step struct {
+	evt     Event
+	ctx     context.Context
+	always  bool
+	target  State
+	async   func(done func(State))
+	timeout *Timeout
+	entry   uint64
+}
+
+// Step applies the next queued step of a machine created with Config.StepMode
+// and reports whether more steps are queued. The error of an applied event is
+// returned as Send would have returned it. Applying a step may queue new ones,
+// for example entering a state with an Always transition queues that transition.
+// An Always transition or a fired timeout queued in a state which has been left
+// since is dropped. Once the machine is stopped, ErrStopped is returned
+func (m *Machine) Step() (bool, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if m.stopped {
+		return false, ErrStopped
+	}
+
+	var next step
+	for {
+		if len(m.steps) == 0 {
+			return false, nil
+		}
+
+		next = m.steps[0]
+		m.steps = m.steps[1:]
+
+		if (next.always || next.timeout != nil) && next.entry != m.entries {
+			continue
+		}
+		break
+	}
+
+	m.stepping = true
+	defer func() {
+		m.stepping = false
+	}()
+
+	var err error
+	switch {
+	case next.always:
+		err = m.enter(next.target, next.async)
+	case next.timeout != nil:
+		m.fireTimeout(next.timeout)
+	default:
+		m.ctx = next.ctx
+		err = m.handle(next.evt)
+		m.ctx = nil
+	}
+
+	return len(m.steps) > 0, err
+}
@@ -0,0 +1,162 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestStepMode(t *testing.T) {
+	const (
+		EvtSubmit = fsm.Event("submit")
+	)
+
+	const (
+		_ fsm.State = iota
+		idle
+		validating
+		routing
+		accepted
+	)
+
+	forward := func(target fsm.State) fsm.Targets {
+		return fsm.Targets{
+			{
+				Target: target,
+			},
+		}
+	}
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial:  idle,
+		StepMode: true,
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event:   EvtSubmit,
+						Targets: forward(validating),
+					},
+				},
+			},
+			{
+				Ref:    validating,
+				Always: forward(routing),
+			},
+			{
+				Ref:    routing,
+				Always: forward(accepted),
+			},
+			{
+				Ref: accepted,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	err = m.Send(EvtSubmit)
+	if err != nil {
+		t.Errorf("failed to queue submit: %s", err)
+		return
+	}
+
+	if m.State() != idle {
+		t.Errorf("expected sent event to be queued, but got %d state", m.State())
+	}
+
+	testCases := []struct {
+		description   string
+		expectedState fsm.State
+		expectedMore  bool
+	}{
+		{
+			description:   "applying submit",
+			expectedState: validating,
+			expectedMore:  true,
+		},
+		{
+			description:   "applying the first hop of the chain",
+			expectedState: routing,
+			expectedMore:  true,
+		},
+		{
+			description:   "applying the second hop of the chain",
+			expectedState: accepted,
+			expectedMore:  false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		more, err := m.Step()
+		if err != nil {
+			t.Errorf("in %s, unexpected error: %s", testCase.description, err)
+		}
+
+		if more != testCase.expectedMore {
+			t.Errorf("in %s, expected more to be %t", testCase.description, testCase.expectedMore)
+		}
+
+		if m.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, m.State())
+		}
+	}
+}
+
+func TestStepModeStaleTimeout(t *testing.T) {
+	const (
+		_ fsm.State = iota
+		waiting
+		done
+		expired
+	)
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial:  waiting,
+		StepMode: true,
+		States: fsm.States{
+			{
+				Ref: waiting,
+				On: fsm.On{
+					{
+						Event:   "go",
+						Targets: fsm.Targets{{Target: done}},
+					},
+				},
+				Timeout: &fsm.Timeout{
+					Duration: 20 * time.Millisecond,
+					Targets:  fsm.Targets{{Target: expired}},
+				},
+			},
+			{Ref: done},
+			{Ref: expired},
+		},
+	})
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+	defer m.Stop()
+
+	m.Send("go")
+	time.Sleep(100 * time.Millisecond)
+
+	more, err := m.Step()
+	if err != nil || !more || m.State() != done {
+		t.Errorf("expected go to be applied with the timeout queued, but got %d state, %t and %v", m.State(), more, err)
+	}
+
+	more, err = m.Step()
+	if err != nil || more {
+		t.Errorf("expected nothing left to apply, but got %t and %v", more, err)
+	}
+
+	if m.State() != done {
+		t.Errorf("expected the timeout of the left state to be dropped, but got %d state", m.State())
+	}
+}
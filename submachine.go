@@ -0,0 +1,83 @@
+package fsm
+
+import "errors"
+
+// isFinal reports whether the state has no way to move on
+func (s *stateInfo) isFinal() bool {
+	if len(s.Events) > 0 || len(s.Always) > 0 {
+		return false
+	}
+
+	for _, timeout := range s.Timeouts {
+		if len(timeout.Targets) > 0 || timeout.RevertOnTimeout {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bindSubMachines makes every sub machine report back once it is done, the
+// report happens in a new goroutine as the sub machine is locked at that moment.
+// Sub machines are halted until their state is entered, so their timeouts only
+// run while their state is active
+func (m *Machine) bindSubMachines() {
+	for ref, stateInfo := range m.states {
+		if stateInfo.SubMachine == nil {
+			continue
+		}
+
+		ref, sub := ref, stateInfo.SubMachine
+
+		sub.mu.Lock()
+		sub.onFinal = func() {
+			go m.subMachineDone(ref)
+		}
+		sub.cancelTimeouts()
+		sub.unlock()
+	}
+}
+
+func (m *Machine) subMachineDone(ref State) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	// the machine might have left the state already
+	if m.stopped || m.currentState != ref {
+		return
+	}
+
+	stateInfo := m.states[ref]
+	if stateInfo.OnDone == "" {
+		return
+	}
+
+	// guard panics are already reported by handle
+	err := m.handle(stateInfo.OnDone)
+	if err != nil && !errors.Is(err, ErrGuardPanic) {
+		m.reportError(err)
+	}
+}
+
+// reset moves the machine back to its initial state
+func (m *Machine) reset() {
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.process(m.initial)
+}
+
+// haltSubMachine halts the sub machine of the current state, if any
+func (m *Machine) haltSubMachine() {
+	if stateInfo, ok := m.states[m.currentState]; ok && stateInfo.SubMachine != nil {
+		stateInfo.SubMachine.halt()
+	}
+}
+
+// halt cancels all the armed timeouts so the machine stays where it is
+func (m *Machine) halt() {
+	m.mu.Lock()
+	defer m.unlock()
+
+	m.cancelTimeouts()
+}
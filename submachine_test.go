@@ -0,0 +1,303 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestSubMachine(t *testing.T) {
+	const (
+		EvtStart    = fsm.Event("start")
+		EvtValidate = fsm.Event("validate")
+		EvtCharge   = fsm.Event("charge")
+		EvtCancel   = fsm.Event("cancel")
+		EvtFinished = fsm.Event("finished")
+	)
+
+	const (
+		_ fsm.State = iota
+		idle
+		processing
+		completed
+		canceled
+	)
+
+	const (
+		_ fsm.State = iota
+		validating
+		charging
+		charged
+	)
+
+	payment, err := fsm.NewMachine(fsm.Config{
+		Initial: validating,
+		States: fsm.States{
+			{
+				Ref: validating,
+				On: fsm.On{
+					{
+						Event: EvtValidate,
+						Targets: fsm.Targets{
+							{
+								Target: charging,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: charging,
+				On: fsm.On{
+					{
+						Event: EvtCharge,
+						Targets: fsm.Targets{
+							{
+								Target: charged,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: charged,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized sub machine: %s", err)
+		return
+	}
+
+	order, err := fsm.NewMachine(fsm.Config{
+		Initial: idle,
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event: EvtStart,
+						Targets: fsm.Targets{
+							{
+								Target: processing,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref:        processing,
+				SubMachine: payment,
+				OnDone:     EvtFinished,
+				On: fsm.On{
+					{
+						Event: EvtFinished,
+						Targets: fsm.Targets{
+							{
+								Target: completed,
+							},
+						},
+					},
+					{
+						Event: EvtCancel,
+						Targets: fsm.Targets{
+							{
+								Target: canceled,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref: completed,
+			},
+			{
+				Ref: canceled,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description      string
+		event            fsm.Event
+		sendError        error
+		expectedState    fsm.State
+		expectedSubState fsm.State
+	}{
+		{
+			description:      "starting the order",
+			event:            EvtStart,
+			expectedState:    processing,
+			expectedSubState: validating,
+		},
+		{
+			description:      "validating is handled by the sub machine",
+			event:            EvtValidate,
+			expectedState:    processing,
+			expectedSubState: charging,
+		},
+		{
+			description:      "unknown event bubbles up",
+			event:            EvtStart,
+			sendError:        fsm.ErrNoop,
+			expectedState:    processing,
+			expectedSubState: charging,
+		},
+		{
+			description:      "charging completes the sub machine",
+			event:            EvtCharge,
+			expectedState:    completed,
+			expectedSubState: charged,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err = order.Send(testCase.event)
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %s, but got %s error", testCase.description, testCase.sendError, err)
+		}
+
+		waitFor(time.Second, func() bool {
+			return order.State() == testCase.expectedState
+		})
+
+		if order.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, order.State())
+		}
+
+		if payment.State() != testCase.expectedSubState {
+			t.Errorf("in %s, expected %d sub state but got %d", testCase.description, testCase.expectedSubState, payment.State())
+		}
+	}
+}
+
+func TestSubMachineTimeoutCascade(t *testing.T) {
+	const (
+		EvtStart = fsm.Event("start")
+	)
+
+	const (
+		_ fsm.State = iota
+		idle
+		session
+		expired
+	)
+
+	const (
+		_ fsm.State = iota
+		typing
+		idling
+	)
+
+	// the children keep moving each other on a short timeout
+	tick := func(target fsm.State) *fsm.Timeout {
+		return &fsm.Timeout{
+			Duration: 20 * time.Millisecond,
+			Targets: fsm.Targets{
+				{
+					Target: target,
+				},
+			},
+		}
+	}
+
+	activity, err := fsm.NewMachine(fsm.Config{
+		Initial: typing,
+		States: fsm.States{
+			{
+				Ref:     typing,
+				Timeout: tick(idling),
+			},
+			{
+				Ref:     idling,
+				Timeout: tick(typing),
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized sub machine: %s", err)
+		return
+	}
+
+	m, err := fsm.NewMachine(fsm.Config{
+		Initial: idle,
+		States: fsm.States{
+			{
+				Ref: idle,
+				On: fsm.On{
+					{
+						Event: EvtStart,
+						Targets: fsm.Targets{
+							{
+								Target: session,
+							},
+						},
+					},
+				},
+			},
+			{
+				Ref:        session,
+				SubMachine: activity,
+				Timeout: &fsm.Timeout{
+					Duration: 200 * time.Millisecond,
+					Targets: fsm.Targets{
+						{
+							Target: expired,
+						},
+					},
+				},
+			},
+			{
+				Ref: expired,
+			},
+		},
+	})
+
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if activity.TransitionCount() != 0 {
+		t.Errorf("expected the sub machine to be halted until its state is entered, but got %d transitions", activity.TransitionCount())
+	}
+
+	start := time.Now()
+	m.Send(EvtStart)
+
+	ok := waitFor(time.Second, func() bool {
+		return activity.TransitionCount() > 0
+	})
+	if !ok || m.State() != session {
+		t.Errorf("expected the child timeout to fire first while in %d state, but got %d", session, m.State())
+	}
+
+	ok = waitFor(time.Second, func() bool {
+		return m.State() == expired
+	})
+	if !ok {
+		t.Errorf("expected the parent timeout to keep counting and expire, but got %d", m.State())
+		return
+	}
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected the parent to expire after 200ms, but it took %s", elapsed)
+	}
+
+	moves := activity.TransitionCount()
+	time.Sleep(50 * time.Millisecond)
+
+	if activity.TransitionCount() != moves {
+		t.Errorf("expected the child timeouts to be canceled once the parent left the state")
+	}
+}
@@ -0,0 +1,76 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// occupancy is a period of time the machine spent in a state,
+// the last one has no end as the machine is still in it
+type occupancy struct {
+	state State
+	start time.Time
+	end   time.Time
+}
+
+// record adds the state which was just entered to the timeline
+func (m *Machine) record() {
+	if !m.recordTimeline {
+		return
+	}
+
+	if last := len(m.timeline) - 1; last >= 0 {
+		m.timeline[last].end = m.enteredAt
+	}
+
+	m.timeline = append(m.timeline, occupancy{
+		state: m.currentState,
+		start: m.enteredAt,
+	})
+}
+
+// ToMermaidGantt returns the recorded timeline as a Mermaid gantt chart, every
+// state has its own section with a bar for each time the machine was in it and
+// the current state's last bar ends now. Config.RecordTimeline must be set for
+// the timeline to be recorded
+func (m *Machine) ToMermaidGantt() string {
+	m.mu.Lock()
+	defer m.unlock()
+
+	now := m.clock.Now()
+
+	var sections []State
+	bars := make(map[State][]occupancy)
+	for _, o := range m.timeline {
+		if o.end.IsZero() {
+			o.end = now
+		}
+
+		if _, ok := bars[o.state]; !ok {
+			sections = append(sections, o.state)
+		}
+		bars[o.state] = append(bars[o.state], o)
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("gantt\n")
+	sb.WriteString("    dateFormat x\n")
+	sb.WriteString("    axisFormat %H:%M:%S\n")
+
+	for _, state := range sections {
+		name := m.stateName(state)
+
+		fmt.Fprintf(&sb, "    section %s\n", name)
+		for _, o := range bars[state] {
+			fmt.Fprintf(&sb, "    %s : %d, %d\n", name, milliseconds(o.start), milliseconds(o.end))
+		}
+	}
+
+	return sb.String()
+}
+
+func milliseconds(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
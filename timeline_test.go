@@ -0,0 +1,60 @@
+package fsm_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestToMermaidGantt(t *testing.T) {
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+
+	conf := trafficLightConfig(time.Hour)
+	conf.Clock = clock
+	conf.RecordTimeline = true
+
+	light, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create traffic light fsm: %s", err)
+		return
+	}
+	defer light.Stop()
+
+	// red, green and yellow last 5s, 3s and 2s for two cycles
+	durations := []time.Duration{5 * time.Second, 3 * time.Second, 2 * time.Second}
+	for i := 0; i < 6; i++ {
+		clock.Advance(durations[i%len(durations)])
+		light.Send(evtToggle)
+	}
+	clock.Advance(time.Second)
+
+	gantt := light.ToMermaidGantt()
+
+	at := func(d time.Duration) int64 {
+		return start.Add(d).UnixNano() / int64(time.Millisecond)
+	}
+
+	expected := []string{
+		"gantt\n",
+		"    section red\n" +
+			fmt.Sprintf("    red : %d, %d\n", at(0), at(5*time.Second)) +
+			fmt.Sprintf("    red : %d, %d\n", at(10*time.Second), at(15*time.Second)) +
+			fmt.Sprintf("    red : %d, %d\n", at(20*time.Second), at(21*time.Second)),
+		"    section green\n" +
+			fmt.Sprintf("    green : %d, %d\n", at(5*time.Second), at(8*time.Second)) +
+			fmt.Sprintf("    green : %d, %d\n", at(15*time.Second), at(18*time.Second)),
+		"    section yellow\n" +
+			fmt.Sprintf("    yellow : %d, %d\n", at(8*time.Second), at(10*time.Second)) +
+			fmt.Sprintf("    yellow : %d, %d\n", at(18*time.Second), at(20*time.Second)),
+	}
+
+	for _, value := range expected {
+		if !strings.Contains(gantt, value) {
+			t.Errorf("expected gantt to contain %q, but got:\n%s", value, gantt)
+		}
+	}
+}
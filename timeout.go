@@ -0,0 +1,189 @@
+package fsm
+
+import "time"
+
+// PendingTimeoutTarget returns the state the machine moves to once the next armed
+// timeout fires, it returns false if no timeout with targets is armed or none of
+// its targets passes. The targets' guards are called to predict the target, so
+// they run once more when the timeout actually fires
+func (m *Machine) PendingTimeoutTarget() (State, bool) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	var next *armedTimeout
+	for _, armed := range m.timeouts {
+		if len(m.timeoutTargets(armed.timeout)) == 0 {
+			continue
+		}
+
+		if next == nil || armed.deadline.Before(next.deadline) {
+			next = armed
+		}
+	}
+
+	if next == nil {
+		return 0, false
+	}
+
+	for _, target := range m.timeoutTargets(next.timeout) {
+		if !m.passes(target.Cond, target.CondCtx, target.Cached) {
+			continue
+		}
+
+		return target.Target, true
+	}
+
+	return 0, false
+}
+
+// CurrentTimeout returns a copy of the configuration of the current state's
+// armed timeout, the first declared one if several are armed, it returns false
+// if no timeout is armed
+func (m *Machine) CurrentTimeout() (*Timeout, bool) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	stateInfo, ok := m.states[m.currentState]
+	if !ok {
+		return nil, false
+	}
+
+	for _, timeout := range stateInfo.Timeouts {
+		for _, armed := range m.timeouts {
+			if armed.timeout != timeout {
+				continue
+			}
+
+			spec := *timeout
+			spec.Targets = append(Targets(nil), timeout.Targets...)
+
+			return &spec, true
+		}
+	}
+
+	return nil, false
+}
+
+// WouldResetTimeout reports whether sending the event would arm timeouts from
+// scratch, either by moving to a state with timeouts or by re-entering the
+// current one, so a countdown shown to the user restarts. The target is
+// predicted like SendPending does by calling the guards, without taking the
+// transition, and the Always transitions of the target are not followed. With
+// TimeoutCoalesce, re-entering the current state only resets the timeouts which
+// aren't armed. The error the event would fail with is returned, such as ErrNoop
+func (m *Machine) WouldResetTimeout(evt Event) (bool, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if canonical, ok := m.aliases[evt]; ok {
+		evt = canonical
+	}
+
+	if m.stopped {
+		return false, ErrStopped
+	}
+
+	if m.isPaused {
+		return false, ErrPaused
+	}
+
+	// the prediction must not count towards the transition's Count
+	restore := m.keepOccurrences()
+	m.guardErr = nil
+
+	target, _, err := m.resolve(evt)
+
+	restore()
+
+	if m.guardErr != nil {
+		err = m.guardErr
+		m.guardErr = nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	stateInfo, ok := m.states[target]
+	if !ok {
+		return false, ErrStateNotFound
+	}
+
+	for _, timeout := range stateInfo.Timeouts {
+		if !m.coalesce || target != m.currentState || !m.armedFor(target) || !m.isArmed(timeout) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// duration returns the duration to arm the timeout with, picking one of
+// DurationChoices by weight if there are any, Jitter is not included
+func (t *Timeout) duration(r Rand) time.Duration {
+	if len(t.DurationChoices) == 0 {
+		return t.Duration
+	}
+
+	total := 0
+	for _, choice := range t.DurationChoices {
+		total += choice.Weight
+	}
+
+	pick := int(r.Int63n(int64(total)))
+	for _, choice := range t.DurationChoices {
+		if pick < choice.Weight {
+			return choice.Duration
+		}
+		pick -= choice.Weight
+	}
+
+	return t.DurationChoices[len(t.DurationChoices)-1].Duration
+}
+
+// longest returns the longest duration the timeout may be armed with, Jitter is not included
+func (t *Timeout) longest() time.Duration {
+	longest := t.Duration
+	for _, choice := range t.DurationChoices {
+		if choice.Duration > longest {
+			longest = choice.Duration
+		}
+	}
+
+	return longest
+}
+
+// isArmed reports whether the timeout is armed
+func (m *Machine) isArmed(timeout *Timeout) bool {
+	for _, armed := range m.timeouts {
+		if armed.timeout == timeout {
+			return true
+		}
+	}
+
+	return false
+}
+
+// armedFor reports whether all the armed timeouts belong to the state
+func (m *Machine) armedFor(state State) bool {
+	for _, armed := range m.timeouts {
+		if armed.state != state {
+			return false
+		}
+	}
+
+	return true
+}
+
+// keepOccurrences copies the occurrences counted towards the transitions'
+// Count and returns a function which puts them back
+func (m *Machine) keepOccurrences() (restore func()) {
+	occurrences := make(map[Event]int, len(m.occurrences))
+	for counted, count := range m.occurrences {
+		occurrences[counted] = count
+	}
+
+	return func() {
+		m.occurrences = occurrences
+	}
+}
@@ -0,0 +1,569 @@
+package fsm_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestPendingTimeoutTarget(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	target, ok := door.PendingTimeoutTarget()
+	if !ok || target != locked {
+		t.Errorf("expected %d pending target, but got %d (%t)", locked, target, ok)
+	}
+
+	door.Send(evtOpen)
+
+	_, ok = door.PendingTimeoutTarget()
+	if ok {
+		t.Errorf("expected no pending target for the opened door")
+	}
+}
+
+func TestOnTimeout(t *testing.T) {
+	type transition struct {
+		from fsm.State
+		to   fsm.State
+	}
+
+	var mu sync.Mutex
+	var timedOut []transition
+
+	conf := trafficLightConfig(20 * time.Millisecond)
+	conf.OnTimeout = func(from, to fsm.State) {
+		mu.Lock()
+		defer mu.Unlock()
+		timedOut = append(timedOut, transition{from, to})
+	}
+
+	light, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create traffic light fsm: %s", err)
+		return
+	}
+
+	err = light.Send(evtToggle)
+	if err != nil {
+		t.Errorf("failed to toggle the light: %s", err)
+		return
+	}
+
+	mu.Lock()
+	if len(timedOut) != 0 {
+		t.Errorf("expected no timeout transition for toggle, but got %v", timedOut)
+	}
+	mu.Unlock()
+
+	ok := waitFor(time.Second, func() bool {
+		return light.State() == red
+	})
+	if !ok {
+		t.Errorf("expected the light to time out back to red, but got %d", light.State())
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []transition{{green, yellow}, {yellow, red}}
+	if len(timedOut) < len(expected) {
+		t.Errorf("expected %v timeout transitions, but got %v", expected, timedOut)
+		return
+	}
+
+	for i, transition := range expected {
+		if timedOut[i] != transition {
+			t.Errorf("expected %v timeout transition, but got %v", transition, timedOut[i])
+		}
+	}
+}
+
+func TestGuardedReEntry(t *testing.T) {
+	const evtRefresh = fsm.Event("refresh")
+
+	valid := true
+	clock := newFakeClock(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	conf := doorConfig()
+	conf.Clock = clock
+	conf.States[0].On = append(conf.States[0].On, fsm.On{
+		{
+			Event: evtRefresh,
+			Cond: func() bool {
+				return valid
+			},
+			Targets: fsm.Targets{
+				{
+					Target: closed,
+				},
+			},
+		},
+	}...)
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	testCases := []struct {
+		description       string
+		valid             bool
+		sendError         error
+		expectedRemaining string
+	}{
+		{
+			description:       "refresh with a valid token",
+			valid:             true,
+			sendError:         nil,
+			expectedRemaining: "remaining 10s",
+		},
+		{
+			description:       "refresh with an expired token",
+			valid:             false,
+			sendError:         fsm.ErrCondFailed,
+			expectedRemaining: "remaining 5s",
+		},
+	}
+
+	for _, testCase := range testCases {
+		clock.Advance(5 * time.Second)
+		valid = testCase.valid
+
+		err = door.Send(evtRefresh)
+		if err != testCase.sendError {
+			t.Errorf("in %s, expect to %v, but got %v error", testCase.description, testCase.sendError, err)
+		}
+
+		description := door.Describe()
+		if !strings.Contains(description, testCase.expectedRemaining) {
+			t.Errorf("in %s, expected auto-lock %s, but got:\n%s", testCase.description, testCase.expectedRemaining, description)
+		}
+	}
+}
+
+func TestRevertOnTimeout(t *testing.T) {
+	const (
+		evtBegin   = fsm.Event("begin")
+		evtConfirm = fsm.Event("confirm")
+	)
+
+	const (
+		_ fsm.State = iota
+		origin
+		pending
+		committed
+	)
+
+	forward := func(evt fsm.Event, target fsm.State) fsm.On {
+		return fsm.On{
+			{
+				Event: evt,
+				Targets: fsm.Targets{
+					{
+						Target: target,
+					},
+				},
+			},
+		}
+	}
+
+	newMachine := func() (*fsm.Machine, error) {
+		return fsm.NewMachine(fsm.Config{
+			Initial: origin,
+			States: fsm.States{
+				{
+					Ref: origin,
+					On:  forward(evtBegin, pending),
+				},
+				{
+					Ref: pending,
+					Timeout: &fsm.Timeout{
+						Duration:        20 * time.Millisecond,
+						RevertOnTimeout: true,
+					},
+					On: forward(evtConfirm, committed),
+				},
+				{
+					Ref: committed,
+				},
+			},
+		})
+	}
+
+	testCases := []struct {
+		description   string
+		confirm       bool
+		expectedState fsm.State
+	}{
+		{
+			description:   "confirmed in time",
+			confirm:       true,
+			expectedState: committed,
+		},
+		{
+			description:   "never confirmed",
+			confirm:       false,
+			expectedState: origin,
+		},
+	}
+
+	for _, testCase := range testCases {
+		m, err := newMachine()
+		if err != nil {
+			t.Errorf("in %s, failed to initialized machine: %s", testCase.description, err)
+			continue
+		}
+
+		m.Send(evtBegin)
+		if testCase.confirm {
+			m.Send(evtConfirm)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		if m.State() != testCase.expectedState {
+			t.Errorf("in %s, expected %d state but got %d", testCase.description, testCase.expectedState, m.State())
+		}
+	}
+}
+
+func TestCurrentTimeout(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	timeout, ok := door.CurrentTimeout()
+	if !ok {
+		t.Errorf("expected the closed door to have an armed timeout")
+		return
+	}
+
+	if timeout.Duration != 10*time.Second {
+		t.Errorf("expected 10s timeout, but got %s", timeout.Duration)
+	}
+
+	if len(timeout.Targets) != 1 || timeout.Targets[0].Target != locked {
+		t.Errorf("expected the timeout to target %d state, but got %+v", locked, timeout.Targets)
+	}
+
+	// the returned timeout is a copy
+	timeout.Duration = time.Second
+	if again, _ := door.CurrentTimeout(); again.Duration != 10*time.Second {
+		t.Errorf("expected the configuration to stay unchanged, but got %s", again.Duration)
+	}
+
+	door.Send(evtOpen)
+
+	_, ok = door.CurrentTimeout()
+	if ok {
+		t.Errorf("expected no armed timeout for the opened door")
+	}
+}
+
+func TestDurationChoices(t *testing.T) {
+	conf := doorConfig()
+	conf.Clock = newFakeClock(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+	conf.Rand = rand.New(rand.NewSource(42))
+	conf.States[0].Timeout = &fsm.Timeout{
+		DurationChoices: fsm.DurationChoices{
+			{Duration: time.Hour, Weight: 1},
+			{Duration: 2 * time.Hour, Weight: 3},
+		},
+		Targets: fsm.Targets{
+			{
+				Target: locked,
+			},
+		},
+	}
+	conf.States[0].On = append(conf.States[0].On, fsm.On{
+		{
+			Event: evtClose,
+			Targets: fsm.Targets{
+				{
+					Target: closed,
+				},
+			},
+		},
+	}...)
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		description := door.Describe()
+		for _, remaining := range []string{"remaining 1h0m0s", "remaining 2h0m0s"} {
+			if strings.Contains(description, remaining) {
+				counts[remaining]++
+			}
+		}
+
+		// closing the closed door re-enters it and arms its timeout again
+		err = door.Send(evtClose)
+		if err != nil {
+			t.Errorf("failed to close the door: %s", err)
+			return
+		}
+	}
+
+	short, long := counts["remaining 1h0m0s"], counts["remaining 2h0m0s"]
+	if short+long != 1000 {
+		t.Errorf("expected every arm to pick one of the choices, but got %d short and %d long", short, long)
+	}
+
+	if short < 200 || short > 300 {
+		t.Errorf("expected about a quarter of the arms to pick the short duration, but got %d", short)
+	}
+
+	conf.States[0].Timeout.Duration = time.Hour
+
+	_, err = fsm.NewMachine(conf)
+	if !errors.Is(err, fsm.ErrInvalidTimeout) {
+		t.Errorf("expected %s error for a timeout with both a duration and choices, but got %v", fsm.ErrInvalidTimeout, err)
+	}
+}
+
+func TestTimeoutArmedCanceled(t *testing.T) {
+	var events []string
+
+	conf := doorConfig()
+	conf.OnTimeoutArmed = func(state fsm.State, d time.Duration) {
+		events = append(events, fmt.Sprintf("armed %d %s", state, d))
+	}
+	conf.OnTimeoutCanceled = func(state fsm.State) {
+		events = append(events, fmt.Sprintf("canceled %d", state))
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	for _, evt := range []fsm.Event{evtOpen, evtClose} {
+		err = door.Send(evt)
+		if err != nil {
+			t.Errorf("failed to send %s: %s", evt, err)
+		}
+	}
+
+	expected := []string{
+		fmt.Sprintf("armed %d 10s", closed),
+		fmt.Sprintf("canceled %d", closed),
+		fmt.Sprintf("armed %d 10s", closed),
+	}
+
+	if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected timeout events:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(events, "\n"))
+	}
+}
+
+func TestTimeoutCoalesce(t *testing.T) {
+	const evtTouch = fsm.Event("touch")
+
+	testCases := []struct {
+		description      string
+		coalesce         bool
+		expectedArmed    int
+		expectedCanceled int
+	}{
+		{
+			description:      "re-entering the closed door",
+			coalesce:         false,
+			expectedArmed:    3,
+			expectedCanceled: 2,
+		},
+		{
+			description:      "re-entering the closed door with coalesced timeouts",
+			coalesce:         true,
+			expectedArmed:    1,
+			expectedCanceled: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		armed, canceled := 0, 0
+
+		conf := doorConfig()
+		conf.TimeoutCoalesce = testCase.coalesce
+		conf.OnTimeoutArmed = func(state fsm.State, d time.Duration) {
+			armed++
+		}
+		conf.OnTimeoutCanceled = func(state fsm.State) {
+			canceled++
+		}
+		conf.States[0].On = append(conf.States[0].On, fsm.On{
+			{
+				Event: evtTouch,
+				Targets: fsm.Targets{
+					{
+						Target: fsm.Self,
+					},
+				},
+			},
+		}...)
+
+		door, err := fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("in %s, failed to create door fsm: %s", testCase.description, err)
+			continue
+		}
+
+		for i := 0; i < 2; i++ {
+			err = door.Send(evtTouch)
+			if err != nil {
+				t.Errorf("in %s, failed to touch the door: %s", testCase.description, err)
+			}
+		}
+
+		if armed != testCase.expectedArmed || canceled != testCase.expectedCanceled {
+			t.Errorf("in %s, expected %d arms and %d cancels, but got %d and %d", testCase.description, testCase.expectedArmed, testCase.expectedCanceled, armed, canceled)
+		}
+
+		door.Stop()
+	}
+}
+
+func TestWouldResetTimeout(t *testing.T) {
+	const evtTouch = fsm.Event("touch")
+
+	testCases := []struct {
+		description   string
+		coalesce      bool
+		event         fsm.Event
+		expectedReset bool
+		expectedErr   error
+	}{
+		{
+			description:   "re-entering the closed door",
+			event:         evtTouch,
+			expectedReset: true,
+		},
+		{
+			description:   "re-entering the closed door with coalesced timeouts",
+			coalesce:      true,
+			event:         evtTouch,
+			expectedReset: false,
+		},
+		{
+			description:   "opening the closed door",
+			event:         evtOpen,
+			expectedReset: false,
+		},
+		{
+			description: "unlocking the closed door",
+			event:       evtUnlock,
+			expectedErr: fsm.ErrNoop,
+		},
+	}
+
+	for _, testCase := range testCases {
+		conf := doorConfig()
+		conf.TimeoutCoalesce = testCase.coalesce
+		conf.States[0].On = append(conf.States[0].On, fsm.On{
+			{
+				Event: evtTouch,
+				Targets: fsm.Targets{
+					{
+						Target: fsm.Self,
+					},
+				},
+			},
+		}...)
+
+		door, err := fsm.NewMachine(conf)
+		if err != nil {
+			t.Errorf("in %s, failed to create door fsm: %s", testCase.description, err)
+			continue
+		}
+
+		reset, err := door.WouldResetTimeout(testCase.event)
+		if err != testCase.expectedErr {
+			t.Errorf("in %s, expected %v error, but got %v", testCase.description, testCase.expectedErr, err)
+		}
+
+		if reset != testCase.expectedReset {
+			t.Errorf("in %s, expected reset to be %t", testCase.description, testCase.expectedReset)
+		}
+
+		if door.State() != closed {
+			t.Errorf("in %s, expected the door to stay closed, but got %d state", testCase.description, door.State())
+		}
+
+		door.Stop()
+	}
+}
+
+type monotonicClock struct {
+	fakeClock
+	monotonic time.Duration
+}
+
+func (c *monotonicClock) Monotonic() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.monotonic
+}
+
+// Advance moves both the wall and the monotonic clock
+func (c *monotonicClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.monotonic += d
+}
+
+func TestMonotonicTimeout(t *testing.T) {
+	clock := &monotonicClock{fakeClock: fakeClock{now: time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)}}
+
+	conf := doorConfig()
+	conf.Clock = clock
+	conf.States[0].Timeout.Duration = 20 * time.Millisecond
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	// the wall clock jumps ahead as the system resumes from a suspend,
+	// while the monotonic clock didn't move
+	clock.Set(clock.Now().Add(time.Hour))
+
+	time.Sleep(100 * time.Millisecond)
+	if door.State() != closed {
+		t.Errorf("expected the door to stay closed across the suspend, but got %d state", door.State())
+		return
+	}
+
+	clock.Advance(20 * time.Millisecond)
+
+	ok := waitFor(time.Second, func() bool {
+		return door.State() == locked
+	})
+	if !ok {
+		t.Errorf("expected the door to lock itself once the timeout elapsed, but got %d state", door.State())
+	}
+}
@@ -0,0 +1,44 @@
+package fsm
+
+import "context"
+
+// Tracer starts a span for every transition, so the machine shows up in
+// distributed traces without depending on a tracing library. An adapter for
+// OpenTelemetry or any other tracer only has to implement these two methods
+type Tracer interface {
+	// StartSpan starts a span named name as a child of ctx, it returns the
+	// span's context and a function which ends the span
+	StartSpan(ctx context.Context, name string) (context.Context, func())
+	// SetAttribute records an attribute on the span of ctx
+	SetAttribute(ctx context.Context, key, value string)
+}
+
+// noopTracer is the default Tracer, it doesn't record anything
+type noopTracer struct{}
+
+func (noopTracer) StartSpan(ctx context.Context, _ string) (context.Context, func()) {
+	return ctx, func() {}
+}
+
+func (noopTracer) SetAttribute(context.Context, string, string) {}
+
+// trace starts the span of a transition caused by the event, a timeout's has
+// an empty event. The span's context is passed to the CondCtx guards until the
+// returned function records the settled state and the error and ends the span
+func (m *Machine) trace(name string, evt Event) func(err error) {
+	prev := m.ctx
+	ctx, end := m.tracer.StartSpan(m.context(), name)
+	m.ctx = ctx
+
+	m.tracer.SetAttribute(ctx, "fsm.from", m.stateName(m.currentState))
+	m.tracer.SetAttribute(ctx, "fsm.event", string(evt))
+
+	return func(err error) {
+		m.tracer.SetAttribute(ctx, "fsm.to", m.stateName(m.currentState))
+		if err != nil {
+			m.tracer.SetAttribute(ctx, "fsm.error", err.Error())
+		}
+		end()
+		m.ctx = prev
+	}
+}
@@ -0,0 +1,120 @@
+package fsm_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+type fakeSpan struct {
+	name  string
+	attrs map[string]string
+	ended bool
+}
+
+type spanKey struct{}
+
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) StartSpan(ctx context.Context, name string) (context.Context, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &fakeSpan{name: name, attrs: make(map[string]string)}
+	t.spans = append(t.spans, span)
+
+	return context.WithValue(ctx, spanKey{}, span), func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		span.ended = true
+	}
+}
+
+func (t *fakeTracer) SetAttribute(ctx context.Context, key, value string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ctx.Value(spanKey{}).(*fakeSpan).attrs[key] = value
+}
+
+func (t *fakeTracer) Spans() []fakeSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spans := make([]fakeSpan, len(t.spans))
+	for i, span := range t.spans {
+		spans[i] = *span
+	}
+
+	return spans
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &fakeTracer{}
+
+	conf := doorConfig()
+	conf.Tracer = tracer
+	conf.States[0].Timeout.Duration = 20 * time.Millisecond
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	for _, evt := range []fsm.Event{evtOpen, evtClose} {
+		err = door.Send(evt)
+		if err != nil {
+			t.Errorf("failed to send %s: %s", evt, err)
+		}
+	}
+
+	ok := waitFor(time.Second, func() bool {
+		return door.State() == locked
+	})
+	if !ok {
+		t.Errorf("expected the door to lock itself, but got %d state", door.State())
+		return
+	}
+
+	err = door.Send(evtOpen)
+	if err != fsm.ErrNoop {
+		t.Errorf("expected %s error, but got %v", fsm.ErrNoop, err)
+	}
+
+	expected := []fakeSpan{
+		{
+			name:  "fsm.send",
+			attrs: map[string]string{"fsm.from": "Closed", "fsm.event": "open", "fsm.to": "Opened"},
+			ended: true,
+		},
+		{
+			name:  "fsm.send",
+			attrs: map[string]string{"fsm.from": "Opened", "fsm.event": "close", "fsm.to": "Closed"},
+			ended: true,
+		},
+		{
+			name:  "fsm.timeout",
+			attrs: map[string]string{"fsm.from": "Closed", "fsm.event": "", "fsm.to": "Locked"},
+			ended: true,
+		},
+		{
+			name:  "fsm.send",
+			attrs: map[string]string{"fsm.from": "Locked", "fsm.event": "open", "fsm.to": "Locked", "fsm.error": fsm.ErrNoop.Error()},
+			ended: true,
+		},
+	}
+
+	spans := tracer.Spans()
+	if !reflect.DeepEqual(spans, expected) {
+		t.Errorf("expected spans %+v, but got %+v", expected, spans)
+	}
+}
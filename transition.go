@@ -0,0 +1,85 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Transition describes a single move of the machine from one state to another
+type Transition struct {
+	From State
+	To   State
+	// Event is the event which caused the transition, it is empty
+	// for Always transitions and transitions taken by a timeout
+	Event Event
+	// Timeout reports whether a timeout caused the transition
+	Timeout bool
+}
+
+// Next blocks until the machine takes its next transition, caused by an event,
+// an Always transition or a timeout, and returns it. If ctx is done first, ctx's
+// error is returned, if the machine is stopped, ErrStopped is returned
+func (m *Machine) Next(ctx context.Context) (Transition, error) {
+	m.mu.Lock()
+	if m.stopped {
+		m.unlock()
+		return Transition{}, ErrStopped
+	}
+
+	// buffered, so emit never blocks on an observer
+	observer := make(chan Transition, 1)
+	m.observers = append(m.observers, observer)
+	m.unlock()
+
+	select {
+	case transition, ok := <-observer:
+		if !ok {
+			return Transition{}, ErrStopped
+		}
+		return transition, nil
+	case <-ctx.Done():
+		m.mu.Lock()
+		defer m.unlock()
+
+		for i, o := range m.observers {
+			if o == observer {
+				m.observers = append(m.observers[:i:i], m.observers[i+1:]...)
+				break
+			}
+		}
+
+		return Transition{}, ctx.Err()
+	}
+}
+
+// emit hands the transition to everyone waiting in Next, each of them
+// only waits for a single transition, so they are all removed
+func (m *Machine) emit(transition Transition) {
+	for _, observer := range m.observers {
+		observer <- transition
+	}
+	m.observers = nil
+}
+
+// logTransition writes the transition to TransitionLog, a failed write is
+// reported to the OnError handlers
+func (m *Machine) logTransition(from, to State, byForce bool) {
+	if m.transitionLog == nil {
+		return
+	}
+
+	evt := string(m.event)
+	switch {
+	case byForce:
+		evt = "<timeout>"
+	case evt == "":
+		evt = "<always>"
+	}
+
+	line := fmt.Sprintf("%s from=%s to=%s event=%s\n", m.clock.Now().Format(time.RFC3339Nano), m.stateName(from), m.stateName(to), evt)
+	if _, err := io.WriteString(m.transitionLog, line); err != nil {
+		m.reportError(err)
+	}
+}
@@ -0,0 +1,114 @@
+package fsm_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestNext(t *testing.T) {
+	door, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+
+	type result struct {
+		transition fsm.Transition
+		err        error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		transition, err := door.Next(context.Background())
+		results <- result{transition, err}
+	}()
+
+	// Next might not be waiting yet, so the event is sent
+	// until the transition it returns is observed
+	var r result
+	for received := false; !received; {
+		door.Send(evtOpen)
+		door.Send(evtClose)
+
+		select {
+		case r = <-results:
+			received = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if r.err != nil {
+		t.Errorf("failed to wait for the next transition: %s", r.err)
+	}
+
+	expected := fsm.Transition{From: closed, To: opened, Event: evtOpen}
+	if r.transition != expected {
+		t.Errorf("expected %+v transition, but got %+v", expected, r.transition)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = door.Next(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %s error, but got %v", context.DeadlineExceeded, err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		door.Stop()
+	}()
+
+	_, err = door.Next(context.Background())
+	if err != fsm.ErrStopped {
+		t.Errorf("expected %s error once stopped, but got %v", fsm.ErrStopped, err)
+	}
+
+	err = door.Send(evtOpen)
+	if err != fsm.ErrStopped {
+		t.Errorf("expected %s error once stopped, but got %v", fsm.ErrStopped, err)
+	}
+}
+
+func TestTransitionLog(t *testing.T) {
+	var buf bytes.Buffer
+
+	conf := doorConfig()
+	conf.Clock = newFakeClock(time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+	conf.TransitionLog = &buf
+	conf.States[0].Timeout.Duration = 20 * time.Millisecond
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	for _, evt := range []fsm.Event{evtOpen, evtClose} {
+		err = door.Send(evt)
+		if err != nil {
+			t.Errorf("failed to send %s: %s", evt, err)
+		}
+	}
+
+	ok := waitFor(time.Second, func() bool {
+		return door.State() == locked
+	})
+	if !ok {
+		t.Errorf("expected the door to lock itself, but got %d state", door.State())
+		return
+	}
+
+	expected := "2021-01-01T12:00:00Z from=Closed to=Opened event=open\n" +
+		"2021-01-01T12:00:00Z from=Opened to=Closed event=close\n" +
+		"2021-01-01T12:00:00Z from=Closed to=Locked event=<timeout>\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected transition log:\n%s\nbut got:\n%s", expected, buf.String())
+	}
+}
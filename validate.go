@@ -0,0 +1,68 @@
+package fsm
+
+import "fmt"
+
+// Validate runs the checks NewMachine runs against the machine's current
+// transition table, so a machine edited with AddState, AddTransition or
+// RemoveTransition can be verified to still be sound. It returns the first
+// violation, the problems tolerated by Warnings are reported there instead.
+// Transitions are keyed by state and event, so they can't be duplicated
+func (m *Machine) Validate() error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if _, ok := m.states[m.initial]; !ok {
+		return fmt.Errorf("initial state %d: %w", m.initial, ErrStateNotFound)
+	}
+
+	if m.contiguous != nil {
+		err := validateContiguous(*m.contiguous, m.states)
+		if err != nil {
+			return err
+		}
+	}
+
+	if errs := validateTargets(m.order, m.states, m.nextStates); len(errs) > 0 {
+		return errs[0]
+	}
+
+	if errs := validateTimeouts(m.order, m.states); len(errs) > 0 {
+		return errs[0]
+	}
+
+	err := validateLoops(m.states)
+	if err != nil {
+		return err
+	}
+
+	err = validateUnreachable(m.order, m.states, m.nextStates, m.warnings)
+	if err != nil {
+		return err
+	}
+
+	err = validateAliases(m.aliases, m.states, m.nextStates)
+	if err != nil {
+		return err
+	}
+
+	if m.strictReach {
+		return validateReachable(m.initial, m.order, m.states, m.nextStates)
+	}
+
+	return nil
+}
+
+// Validate runs every check NewMachine runs against the configuration without
+// creating a machine, and unlike NewMachine, which stops at the first problem,
+// it keeps going and returns all of them, e.g. duplicate states, dangling
+// targets, invalid timeouts and, with StrictReachability, unreachable states.
+// It returns nil if NewMachine would accept the configuration
+func (conf Config) Validate() []error {
+	compiled, errs := build(conf, true)
+
+	if _, ok := compiled.states[conf.Initial]; conf.Initial != 0 && !ok {
+		errs = append(errs, fmt.Errorf("initial state %d: %w", conf.Initial, ErrStateNotFound))
+	}
+
+	return errs
+}
@@ -0,0 +1,156 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// transitionEdge is a single declared move from one state to another
+type transitionEdge struct {
+	From      State
+	Event     Event
+	To        State
+	IsTimeout bool
+	Guarded   bool
+	Duration  time.Duration
+	Timeout   *Timeout
+	Label     string
+	Color     string
+}
+
+// label describes the edge for the diagram exporters, timeouts are labeled with
+// their duration and guarded transitions are marked with [cond], unless the
+// transition defines its own Label
+func (e transitionEdge) label() string {
+	if e.Label != "" {
+		return e.Label
+	}
+
+	var label []string
+	switch {
+	case e.IsTimeout:
+		label = append(label, fmt.Sprintf("after %s", e.Duration))
+	case e.Event != "":
+		label = append(label, string(e.Event))
+	}
+	if e.Guarded {
+		label = append(label, "[cond]")
+	}
+
+	return strings.Join(label, " ")
+}
+
+// edgesFrom returns all the declared edges leaving the given state, event
+// transitions come first in the order of declaration, then Always transitions
+// and finally timeouts, Always and timeout edges have an empty event
+func (m *Machine) edgesFrom(state State) []transitionEdge {
+	stateInfo, ok := m.states[state]
+	if !ok {
+		return nil
+	}
+
+	var edges []transitionEdge
+
+	for _, evt := range stateInfo.Events {
+		stateEventInfo := m.nextStates[key{state, evt}]
+		for _, target := range stateEventInfo.Targets {
+			edges = append(edges, transitionEdge{
+				From:    state,
+				Event:   evt,
+				To:      target.Target,
+				Guarded: guarded(stateEventInfo.Cond, stateEventInfo.CondCtx, stateEventInfo.Cached) || guarded(target.Cond, target.CondCtx, target.Cached),
+				Label:   firstOf(target.Label, stateEventInfo.Label),
+				Color:   firstOf(target.Color, stateEventInfo.Color),
+			})
+		}
+	}
+
+	for _, target := range stateInfo.Always {
+		edges = append(edges, transitionEdge{
+			From:    state,
+			To:      target.Target,
+			Guarded: guarded(target.Cond, target.CondCtx, target.Cached),
+			Label:   target.Label,
+			Color:   target.Color,
+		})
+	}
+
+	for _, timeout := range stateInfo.Timeouts {
+		for _, target := range timeout.Targets {
+			edges = append(edges, transitionEdge{
+				From:      state,
+				To:        target.Target,
+				IsTimeout: true,
+				Guarded:   timeout.Cond != nil || guarded(target.Cond, target.CondCtx, target.Cached),
+				Duration:  timeout.longest(),
+				Timeout:   timeout,
+				Label:     target.Label,
+				Color:     target.Color,
+			})
+		}
+	}
+
+	return edges
+}
+
+// Walk performs a breadth first search over all declared transitions starting
+// from the initial state and calls visit for every edge, guards are ignored.
+// Always transitions and timeouts are visited with an empty event, the latter
+// with isTimeout set. Walk stops as soon as visit returns false. The machine is
+// not locked while visit runs, so it is safe to call the machine from visit
+func (m *Machine) Walk(visit func(from State, evt Event, to State, isTimeout bool) bool) {
+	m.mu.Lock()
+	edges := m.walk()
+	m.unlock()
+
+	for _, edge := range edges {
+		if !visit(edge.From, edge.Event, edge.To, edge.IsTimeout) {
+			return
+		}
+	}
+}
+
+// allEdges returns the edges of every declared state in the order of declaration
+func (m *Machine) allEdges() []transitionEdge {
+	var edges []transitionEdge
+	for _, state := range m.order {
+		edges = append(edges, m.edgesFrom(state)...)
+	}
+
+	return edges
+}
+
+func (m *Machine) walk() []transitionEdge {
+	var edges []transitionEdge
+
+	visited := map[State]bool{m.initial: true}
+	queue := []State{m.initial}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for _, edge := range m.edgesFrom(state) {
+			edges = append(edges, edge)
+
+			if !visited[edge.To] {
+				visited[edge.To] = true
+				queue = append(queue, edge.To)
+			}
+		}
+	}
+
+	return edges
+}
+
+// firstOf returns the first non empty value
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,40 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestWalk(t *testing.T) {
+	light, err := fsm.NewMachine(trafficLightConfig(time.Minute))
+	if err != nil {
+		t.Errorf("failed to initialized machine: %s", err)
+		return
+	}
+
+	events, timeouts := 0, 0
+	light.Walk(func(from fsm.State, evt fsm.Event, to fsm.State, isTimeout bool) bool {
+		if isTimeout {
+			timeouts++
+		} else {
+			events++
+		}
+		return true
+	})
+
+	if events != 3 || timeouts != 3 {
+		t.Errorf("expected 3 event and 3 timeout edges, but got %d and %d", events, timeouts)
+	}
+
+	visited := 0
+	light.Walk(func(from fsm.State, evt fsm.Event, to fsm.State, isTimeout bool) bool {
+		visited++
+		return visited < 2
+	})
+
+	if visited != 2 {
+		t.Errorf("expected walk to stop after 2 edges, but visited %d", visited)
+	}
+}
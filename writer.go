@@ -0,0 +1,73 @@
+package fsm
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// EventWriter is an io.Writer which sends every newline separated token
+// written to it as an event to the machine, created by Machine.EventWriter
+type EventWriter struct {
+	errors uint64
+
+	mu      sync.Mutex
+	machine *Machine
+	partial []byte
+}
+
+// EventWriter returns a writer which sends every newline separated token written
+// to it as an event, so events can be piped into the machine from a file or a
+// socket. Surrounding spaces and empty lines are ignored, and a token is only
+// sent once its newline is written, Close sends the last one if it has none.
+// Writes never fail, the tokens rejected by Send are counted by Errors instead
+func (m *Machine) EventWriter() *EventWriter {
+	return &EventWriter{machine: m}
+}
+
+// Write sends every complete token in p as an event and keeps the rest until
+// the next Write
+func (w *EventWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.send(w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Close sends the last token if it isn't followed by a newline
+func (w *EventWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.send(w.partial)
+	w.partial = nil
+
+	return nil
+}
+
+// Errors returns the number of tokens rejected by Send
+func (w *EventWriter) Errors() uint64 {
+	return atomic.LoadUint64(&w.errors)
+}
+
+func (w *EventWriter) send(token []byte) {
+	token = bytes.TrimSpace(token)
+	if len(token) == 0 {
+		return
+	}
+
+	if err := w.machine.Send(Event(token)); err != nil {
+		atomic.AddUint64(&w.errors, 1)
+	}
+}
@@ -0,0 +1,45 @@
+package fsm_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestEventWriter(t *testing.T) {
+	light, err := fsm.NewMachine(trafficLightConfig(time.Hour))
+	if err != nil {
+		t.Errorf("failed to create traffic light fsm: %s", err)
+		return
+	}
+
+	w := light.EventWriter()
+
+	_, err = io.Copy(w, strings.NewReader("toggle\ntoggle\n"))
+	if err != nil {
+		t.Errorf("failed to write events: %s", err)
+	}
+
+	if light.TransitionCount() != 2 {
+		t.Errorf("expected 2 transitions but got %d", light.TransitionCount())
+	}
+
+	if light.State() != yellow {
+		t.Errorf("expected %d state but got %d", yellow, light.State())
+	}
+
+	w.Write([]byte("flash\ntog"))
+	w.Write([]byte("gle"))
+	w.Close()
+
+	if w.Errors() != 1 {
+		t.Errorf("expected 1 unrecognized event but got %d", w.Errors())
+	}
+
+	if light.State() != red {
+		t.Errorf("expected the split token to be sent, %d state but got %d", red, light.State())
+	}
+}
@@ -0,0 +1,358 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+type xstateMachine struct {
+	ID      string                 `json:"id"`
+	Initial string                 `json:"initial"`
+	States  map[string]xstateState `json:"states"`
+}
+
+type xstateState struct {
+	On     map[string][]xstateTransition `json:"on,omitempty"`
+	Always []xstateTransition            `json:"always,omitempty"`
+	After  map[string][]xstateTransition `json:"after,omitempty"`
+}
+
+type xstateTransition struct {
+	Target string `json:"target"`
+	Cond   string `json:"cond,omitempty"`
+}
+
+// ToXState returns the machine as an xstate machine config in JSON, states are
+// keyed by their names, timeouts are listed under after by their duration in
+// milliseconds, the longest one for DurationChoices, and guards are referred to
+// by their name in Config.Guards, a CachedCond by its Key and any other guard as
+// "cond". A target with its own guard on a guarded transition gets both guards
+// joined by " && ", which NewMachineFromXState resolves back to both of them.
+// Timeouts without targets and RevertOnTimeout timeouts can't be expressed and
+// are left out. Two states with the same name fail the export
+func (m *Machine) ToXState() ([]byte, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	names := make([]string, 0, len(m.guards))
+	for name := range m.guards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// a closure registered under several names gets the first one
+	guardNames := make(map[unsafe.Pointer]string, len(names))
+	for _, name := range names {
+		id := funcID(m.guards[name])
+		if _, ok := guardNames[id]; !ok {
+			guardNames[id] = name
+		}
+	}
+
+	condName := func(cond func() bool, condCtx func(ctx context.Context) bool, cached *CachedCond) string {
+		switch {
+		case cond != nil:
+			if name, ok := guardNames[funcID(cond)]; ok {
+				return name
+			}
+		case cached != nil:
+			return cached.Key
+		case condCtx == nil:
+			return ""
+		}
+		return "cond"
+	}
+
+	transitions := func(guard string, targets Targets) []xstateTransition {
+		list := make([]xstateTransition, 0, len(targets))
+		for _, target := range targets {
+			cond := condName(target.Cond, target.CondCtx, target.Cached)
+			switch {
+			case cond == "":
+				cond = guard
+			case guard != "":
+				cond = guard + xstateAnd + cond
+			}
+
+			list = append(list, xstateTransition{
+				Target: m.stateName(target.Target),
+				Cond:   cond,
+			})
+		}
+		return list
+	}
+
+	machine := xstateMachine{
+		ID:      "fsm",
+		Initial: m.stateName(m.initial),
+		States:  make(map[string]xstateState, len(m.order)),
+	}
+
+	for _, ref := range m.order {
+		name := m.stateName(ref)
+		if _, ok := machine.States[name]; ok {
+			return nil, fmt.Errorf("state ref %d shares the name %s: %w", ref, name, ErrDuplicateState)
+		}
+
+		stateInfo := m.states[ref]
+		state := xstateState{
+			Always: transitions("", stateInfo.Always),
+		}
+
+		for _, evt := range stateInfo.Events {
+			stateEventInfo := m.nextStates[key{ref, evt}]
+			if state.On == nil {
+				state.On = make(map[string][]xstateTransition)
+			}
+			state.On[string(evt)] = transitions(condName(stateEventInfo.Cond, stateEventInfo.CondCtx, stateEventInfo.Cached), stateEventInfo.Targets)
+		}
+
+		for _, timeout := range stateInfo.Timeouts {
+			if len(timeout.Targets) == 0 || timeout.RevertOnTimeout {
+				continue
+			}
+
+			if state.After == nil {
+				state.After = make(map[string][]xstateTransition)
+			}
+
+			var guard string
+			if timeout.Cond != nil {
+				guard = condName(timeout.Cond, nil, nil)
+			}
+
+			delay := strconv.FormatInt(timeout.longest().Milliseconds(), 10)
+			state.After[delay] = append(state.After[delay], transitions(guard, timeout.Targets)...)
+		}
+
+		machine.States[name] = state
+	}
+
+	return json.Marshal(machine)
+}
+
+// xstateAnd joins the guards of a transition which must all pass
+const xstateAnd = " && "
+
+// xstateTargets accepts the forms of an xstate transition, a target name,
+// a transition object or a list of either
+type xstateTargets []xstateTransition
+
+func (t *xstateTargets) UnmarshalJSON(data []byte) error {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		list = []json.RawMessage{data}
+	}
+
+	for _, raw := range list {
+		var target string
+		if err := json.Unmarshal(raw, &target); err == nil {
+			*t = append(*t, xstateTransition{Target: target})
+			continue
+		}
+
+		var transition struct {
+			Target string `json:"target"`
+			Cond   string `json:"cond"`
+			Guard  string `json:"guard"`
+		}
+		if err := json.Unmarshal(raw, &transition); err != nil {
+			return err
+		}
+
+		cond := transition.Cond
+		if cond == "" {
+			cond = transition.Guard
+		}
+		*t = append(*t, xstateTransition{Target: transition.Target, Cond: cond})
+	}
+
+	return nil
+}
+
+// NewMachineFromXState creates a machine from an xstate machine config in JSON,
+// so statecharts authored for JavaScript can be reused. States are numbered in
+// the order of their names and named after them, on, always and after with
+// delays in milliseconds are supported, and the guards are resolved by name
+// from guards, guards joined by " && " must all pass. The targets may refer to states by their name, with or without
+// the machine's id. Nested and parallel states are not supported
+func NewMachineFromXState(data []byte, guards GuardRegistry) (*Machine, error) {
+	var machine struct {
+		ID      string `json:"id"`
+		Initial string `json:"initial"`
+		States  map[string]struct {
+			On     map[string]xstateTargets `json:"on"`
+			Always xstateTargets            `json:"always"`
+			After  map[string]xstateTargets `json:"after"`
+		} `json:"states"`
+	}
+
+	err := json.Unmarshal(data, &machine)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(machine.States))
+	for name := range machine.States {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	conf := Config{
+		Names:  make(map[State]string, len(names)),
+		Guards: guards,
+	}
+
+	refs := make(map[string]State, len(names))
+	for i, name := range names {
+		refs[name] = State(i + 1)
+		conf.Names[State(i+1)] = name
+	}
+
+	if machine.Initial != "" {
+		initial, ok := refs[machine.Initial]
+		if !ok {
+			return nil, fmt.Errorf("initial state %s: %w", machine.Initial, ErrStateNotFound)
+		}
+		conf.Initial = initial
+	}
+
+	var conds []string
+	seen := make(map[string]bool)
+	collect := func(transitions xstateTargets) {
+		for _, transition := range transitions {
+			if transition.Cond == "" {
+				continue
+			}
+			for _, cond := range strings.Split(transition.Cond, xstateAnd) {
+				if !seen[cond] {
+					seen[cond] = true
+					conds = append(conds, cond)
+				}
+			}
+		}
+	}
+
+	for _, name := range names {
+		state := machine.States[name]
+		for _, transitions := range state.On {
+			collect(transitions)
+		}
+		collect(state.Always)
+		for _, transitions := range state.After {
+			collect(transitions)
+		}
+	}
+
+	resolved, err := guards.Resolve(conds...)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := func(from string, transitions xstateTargets) (Targets, error) {
+		var list Targets
+		for _, transition := range transitions {
+			name := transition.Target
+			name = strings.TrimPrefix(name, "#"+machine.ID+".")
+			name = strings.TrimPrefix(name, ".")
+
+			ref, ok := refs[name]
+			if !ok {
+				return nil, fmt.Errorf("state %s targets unknown state %s: %w", from, transition.Target, ErrStateNotFound)
+			}
+
+			list = append(list, Targets{{Target: ref, Cond: xstateCond(transition.Cond, resolved)}}...)
+		}
+		return list, nil
+	}
+
+	for _, name := range names {
+		state := machine.States[name]
+
+		always, err := targets(name, state.Always)
+		if err != nil {
+			return nil, err
+		}
+
+		events := make([]string, 0, len(state.On))
+		for evt := range state.On {
+			events = append(events, evt)
+		}
+		sort.Strings(events)
+
+		var on On
+		for _, evt := range events {
+			eventTargets, err := targets(name, state.On[evt])
+			if err != nil {
+				return nil, err
+			}
+
+			on = append(on, On{{Event: Event(evt), Targets: eventTargets}}...)
+		}
+
+		delays := make([]string, 0, len(state.After))
+		durations := make(map[string]time.Duration, len(state.After))
+		for delay := range state.After {
+			ms, err := strconv.ParseInt(delay, 10, 64)
+			if err != nil || ms < 0 {
+				return nil, fmt.Errorf("state %s has a timeout with %s delay: %w", name, delay, ErrInvalidTimeout)
+			}
+			delays = append(delays, delay)
+			durations[delay] = time.Duration(ms) * time.Millisecond
+		}
+		sort.Slice(delays, func(i, j int) bool {
+			return durations[delays[i]] < durations[delays[j]]
+		})
+
+		var timeouts []*Timeout
+		for _, delay := range delays {
+			timeoutTargets, err := targets(name, state.After[delay])
+			if err != nil {
+				return nil, err
+			}
+
+			timeouts = append(timeouts, &Timeout{
+				Duration:  durations[delay],
+				Immediate: durations[delay] == 0,
+				Targets:   timeoutTargets,
+			})
+		}
+
+		conf.States = append(conf.States, States{{
+			Ref:      refs[name],
+			Always:   always,
+			Timeouts: timeouts,
+			On:       on,
+		}}...)
+	}
+
+	return NewMachine(conf)
+}
+
+// xstateCond returns the guard of a transition, which passes if all
+// the guards joined in its cond pass
+func xstateCond(cond string, resolved map[string]func() bool) func() bool {
+	if cond == "" {
+		return nil
+	}
+
+	names := strings.Split(cond, xstateAnd)
+	if len(names) == 1 {
+		return resolved[cond]
+	}
+
+	return func() bool {
+		for _, name := range names {
+			if !resolved[name]() {
+				return false
+			}
+		}
+		return true
+	}
+}
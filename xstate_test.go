@@ -0,0 +1,264 @@
+package fsm_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinz/fsm.go"
+)
+
+func TestToXState(t *testing.T) {
+	guards := fsm.NewGuardRegistry()
+	guards.Register("hasKey", func() bool { return true })
+
+	conf := doorConfig()
+	conf.Guards = guards
+	conf.States[1].On[0].Cond = guards.MustGet("hasKey")
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	data, err := door.ToXState()
+	if err != nil {
+		t.Errorf("failed to export door: %s", err)
+		return
+	}
+
+	type transition struct {
+		Target string `json:"target"`
+		Cond   string `json:"cond"`
+	}
+
+	var machine struct {
+		Initial string `json:"initial"`
+		States  map[string]struct {
+			On    map[string][]transition `json:"on"`
+			After map[string][]transition `json:"after"`
+		} `json:"states"`
+	}
+
+	err = json.Unmarshal(data, &machine)
+	if err != nil {
+		t.Errorf("failed to parse exported door: %s\n%s", err, data)
+		return
+	}
+
+	if machine.Initial != "Closed" {
+		t.Errorf("expected Closed initial state, but got %q", machine.Initial)
+	}
+
+	after := machine.States["Closed"].After["10000"]
+	if len(after) != 1 || after[0].Target != "Locked" {
+		t.Errorf("expected the closed door to lock after 10000ms, but got %+v", after)
+	}
+
+	unlock := machine.States["Locked"].On["unlock"]
+	if len(unlock) != 1 || unlock[0].Target != "Unlocked" || unlock[0].Cond != "hasKey" {
+		t.Errorf("expected the locked door to unlock with the hasKey guard, but got %+v", unlock)
+	}
+}
+
+func TestNewMachineFromXState(t *testing.T) {
+	data := []byte(`{
+		"id": "light",
+		"initial": "green",
+		"states": {
+			"green": {
+				"on": {
+					"TIMER": "yellow"
+				}
+			},
+			"yellow": {
+				"after": {
+					"20": "#light.red"
+				}
+			},
+			"red": {
+				"on": {
+					"TIMER": [
+						{ "target": "green", "cond": "isDay" },
+						{ "target": "off" }
+					]
+				}
+			},
+			"off": {}
+		}
+	}`)
+
+	day := true
+	guards := fsm.NewGuardRegistry()
+	guards.Register("isDay", func() bool { return day })
+
+	light, err := fsm.NewMachineFromXState(data, guards)
+	if err != nil {
+		t.Errorf("failed to import light: %s", err)
+		return
+	}
+	defer light.Stop()
+
+	stateName := func() string {
+		data, _ := light.StateCodec().MarshalState(light.State())
+		return strings.Trim(string(data), `"`)
+	}
+
+	err = light.Send("TIMER")
+	if err != nil {
+		t.Errorf("failed to send TIMER: %s", err)
+	}
+
+	if name := stateName(); name != "yellow" {
+		t.Errorf("expected yellow state, but got %s", name)
+	}
+
+	ok := waitFor(time.Second, func() bool {
+		return stateName() == "red"
+	})
+	if !ok {
+		t.Errorf("expected the yellow light to turn red after its delay, but got %s", stateName())
+	}
+
+	day = false
+	err = light.Send("TIMER")
+	if err != nil {
+		t.Errorf("failed to send TIMER: %s", err)
+	}
+
+	if name := stateName(); name != "off" {
+		t.Errorf("expected the guarded target to be skipped at night, but got %s", name)
+	}
+
+	_, err = fsm.NewMachineFromXState(data, fsm.NewGuardRegistry())
+	if !errors.Is(err, fsm.ErrGuardNotFound) {
+		t.Errorf("expected %s error without the isDay guard, but got %v", fsm.ErrGuardNotFound, err)
+	}
+}
+
+func TestXStateCombinedGuards(t *testing.T) {
+	admin := false
+	guards := fsm.NewGuardRegistry()
+	guards.Register("hasKey", func() bool { return true })
+	guards.Register("isAdmin", func() bool { return admin })
+
+	conf := doorConfig()
+	conf.Guards = guards
+	conf.States[0].On[1].Cond = guards.MustGet("hasKey")
+	conf.States[0].On[1].Targets[0].Cond = guards.MustGet("isAdmin")
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	data, err := door.ToXState()
+	if err != nil {
+		t.Errorf("failed to export door: %s", err)
+		return
+	}
+
+	var machine struct {
+		States map[string]struct {
+			On map[string][]struct {
+				Cond string `json:"cond"`
+			} `json:"on"`
+		} `json:"states"`
+	}
+
+	err = json.Unmarshal(data, &machine)
+	if err != nil {
+		t.Errorf("failed to parse exported door: %s\n%s", err, data)
+		return
+	}
+
+	open := machine.States["Closed"].On["open"]
+	if len(open) != 1 || open[0].Cond != "hasKey && isAdmin" {
+		t.Errorf("expected the open transition to keep both guards, but got %+v", open)
+	}
+
+	imported, err := fsm.NewMachineFromXState(data, guards)
+	if err != nil {
+		t.Errorf("failed to import door: %s", err)
+		return
+	}
+	defer imported.Stop()
+
+	stateName := func() string {
+		data, _ := imported.StateCodec().MarshalState(imported.State())
+		return strings.Trim(string(data), `"`)
+	}
+
+	imported.Send(evtOpen)
+	if name := stateName(); name != "Closed" {
+		t.Errorf("expected the door to stay closed without admin, but got %s", name)
+	}
+
+	admin = true
+	err = imported.Send(evtOpen)
+	if err != nil {
+		t.Errorf("failed to send %s: %s", evtOpen, err)
+	}
+
+	if name := stateName(); name != "Opened" {
+		t.Errorf("expected the door to open with admin, but got %s", name)
+	}
+}
+
+func TestToXStateClosureGuards(t *testing.T) {
+	other, err := fsm.NewMachine(doorConfig())
+	if err != nil {
+		t.Errorf("failed to create other door fsm: %s", err)
+		return
+	}
+	defer other.Stop()
+
+	guards := fsm.NewGuardRegistry()
+	guards.Register("otherOpened", fsm.InState(other, opened))
+	guards.Register("otherClosed", fsm.InState(other, closed))
+
+	conf := doorConfig()
+	conf.Guards = guards
+	conf.States[0].On[1].Targets = fsm.Targets{
+		{Target: locked, Cond: guards.MustGet("otherOpened")},
+		{Target: opened, Cond: guards.MustGet("otherClosed")},
+	}
+
+	door, err := fsm.NewMachine(conf)
+	if err != nil {
+		t.Errorf("failed to create door fsm: %s", err)
+		return
+	}
+	defer door.Stop()
+
+	data, err := door.ToXState()
+	if err != nil {
+		t.Errorf("failed to export door: %s", err)
+		return
+	}
+
+	var machine struct {
+		States map[string]struct {
+			On map[string][]struct {
+				Cond string `json:"cond"`
+			} `json:"on"`
+		} `json:"states"`
+	}
+
+	err = json.Unmarshal(data, &machine)
+	if err != nil {
+		t.Errorf("failed to parse exported door: %s\n%s", err, data)
+		return
+	}
+
+	open := machine.States["Closed"].On["open"]
+	if len(open) != 2 || open[0].Cond != "otherOpened" || open[1].Cond != "otherClosed" {
+		t.Errorf("expected each target to keep its own guard, but got %+v", open)
+	}
+}